@@ -0,0 +1,280 @@
+package asset
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/output"
+	"github.com/tdewolff/minify"
+	"github.com/tdewolff/minify/css"
+	"github.com/tdewolff/minify/js"
+	"github.com/tdewolff/minify/json"
+	"github.com/tdewolff/minify/svg"
+)
+
+// mimeTypes is the MIME type of asset files that processed, keyed by their extension.
+var mimeTypes = map[string]string{
+	".css":  "text/css",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".svg":  "image/svg+xml",
+}
+
+// Asset is an asset file in theme, e.g. CSS or JS, that has been processed.
+type Asset struct {
+	Name      string
+	URL       string
+	Integrity string
+	Content   []byte
+}
+
+// Manifest is list of processed assets, keyed by their name, e.g. "css/main.css".
+type Manifest struct {
+	assets map[string]Asset
+}
+
+// Process processes the asset files in theme chain, following the asset config. The
+// files in child theme replace the files with the same name in its parent, the same
+// way they're copied into output dir. Bundles that declared in theme manifest and
+// config file are concatenated from their files. If enabled, the assets are minified
+// and their URL contains the fingerprint of their content, e.g. /css/main.3f2a1c.css.
+func Process(chain []model.Theme, config model.AssetConfig) (*Manifest, error) {
+	// Collect asset files, starting from the topmost parent
+	files := map[string]fs.FS{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		err := collectFiles(chain[i].FS, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	contents := map[string][]byte{}
+	for name, themeFS := range files {
+		content, err := fs.ReadFile(themeFS, name)
+		if err != nil {
+			return nil, err
+		}
+		contents[name] = content
+	}
+
+	// Concatenate the bundles. The bundles are only made from the
+	// files in theme, so a bundle can't contain another bundle.
+	bundles := map[string][]byte{}
+	for name, sources := range mergeBundles(chain, config) {
+		if mimeType(name) == "" {
+			return nil, fmt.Errorf("bundle %s is not CSS, JS, JSON or SVG file", name)
+		}
+
+		separator := []byte("\n")
+		if mimeType(name) == "application/javascript" {
+			separator = []byte(";\n")
+		}
+
+		buffer := bytes.Buffer{}
+		for i, source := range sources {
+			content, exist := contents[path.Clean(source)]
+			if !exist {
+				return nil, fmt.Errorf("file %s in bundle %s is not found", source, name)
+			}
+
+			if i > 0 {
+				buffer.Write(separator)
+			}
+			buffer.Write(content)
+		}
+
+		bundles[name] = buffer.Bytes()
+	}
+
+	for name, content := range bundles {
+		contents[name] = content
+	}
+
+	// Minify and fingerprint the assets
+	minifier := minify.New()
+	minifier.AddFunc("text/css", css.Minify)
+	minifier.AddFunc("application/javascript", js.Minify)
+	minifier.AddFunc("application/json", json.Minify)
+	minifier.AddFunc("image/svg+xml", svg.Minify)
+
+	manifest := &Manifest{assets: map[string]Asset{}}
+	for name, content := range contents {
+		if config.Minify {
+			minified, err := minifier.Bytes(mimeType(name), content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to minify %s: %v", name, err)
+			}
+			content = minified
+		}
+
+		sha384 := sha512.Sum384(content)
+		asset := Asset{
+			Name:      name,
+			URL:       "/" + name,
+			Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sha384[:]),
+			Content:   content,
+		}
+
+		if config.Fingerprint {
+			asset.URL = "/" + fingerprintName(name, content)
+		}
+
+		manifest.assets[name] = asset
+	}
+
+	return manifest, nil
+}
+
+// Get returns the asset with the specified name, e.g. "css/main.css".
+func (m *Manifest) Get(name string) (Asset, bool) {
+	asset, exist := m.assets[path.Clean(strings.TrimPrefix(name, "/"))]
+	return asset, exist
+}
+
+// Find returns the asset that served in the specified URL, which is either
+// its fingerprinted URL or the URL from its name.
+func (m *Manifest) Find(assetURL string) (Asset, bool) {
+	if asset, exist := m.Get(assetURL); exist {
+		return asset, true
+	}
+
+	for _, asset := range m.assets {
+		if asset.URL == assetURL {
+			return asset, true
+		}
+	}
+
+	return Asset{}, false
+}
+
+// Files returns path of files that written by Write, relative to output dir.
+func (m *Manifest) Files() []string {
+	files := []string{}
+	for name, asset := range m.assets {
+		files = append(files, name)
+		if asset.URL != "/"+name {
+			files = append(files, strings.TrimPrefix(asset.URL, "/"))
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// Write writes the processed assets into output sink, using modTime as their modification
+// time. Each asset is written using its name, so the files that refer to it directly still
+// work, and using its fingerprinted URL if it's different.
+func (m *Manifest) Write(sink output.Sink, modTime time.Time) error {
+	names := []string{}
+	for name := range m.assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		asset := m.assets[name]
+		dstNames := []string{name}
+		if asset.URL != "/"+name {
+			dstNames = append(dstNames, strings.TrimPrefix(asset.URL, "/"))
+		}
+
+		for _, dstName := range dstNames {
+			err := writeFile(sink, dstName, asset.Content, modTime)
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectFiles collects the asset files inside the sub directories of theme,
+// which are the directories that copied into output dir.
+func collectFiles(themeFS fs.FS, files map[string]fs.FS) error {
+	items, err := fs.ReadDir(themeFS, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+
+		err = fs.WalkDir(themeFS, item.Name(), func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+
+			if mimeType(name) == "" {
+				return nil
+			}
+
+			files[name] = themeFS
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes the content into file with the name in output sink.
+func writeFile(sink output.Sink, name string, content []byte, modTime time.Time) error {
+	f, err := sink.Create(name, modTime)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(content)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+
+	return err
+}
+
+// mergeBundles merges the bundles in theme chain and config. The bundles in child
+// theme replace the bundles in its parent, and the bundles in config replace them all.
+func mergeBundles(chain []model.Theme, config model.AssetConfig) map[string][]string {
+	bundles := map[string][]string{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for name, sources := range chain[i].Bundles {
+			bundles[path.Clean(strings.TrimPrefix(name, "/"))] = sources
+		}
+	}
+
+	for name, sources := range config.Bundles {
+		bundles[path.Clean(strings.TrimPrefix(name, "/"))] = sources
+	}
+
+	return bundles
+}
+
+// fingerprintName adds the fingerprint of content into the file name,
+// e.g. css/main.css becomes css/main.3f2a1c.css.
+func fingerprintName(name string, content []byte) string {
+	hash := sha256.Sum256(content)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(hash[:])[:6] + ext
+}
+
+// mimeType returns the MIME type of asset file from its extension.
+// Returns empty string if the file is not processed as asset.
+func mimeType(name string) string {
+	return mimeTypes[strings.ToLower(path.Ext(name))]
+}
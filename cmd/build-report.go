@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-spook/spook/output"
+	"github.com/go-spook/spook/parser"
+	"github.com/go-spook/spook/site"
+)
+
+// buildReport is the summary of a build, which printed when flag --report is used.
+// Outputs is keyed by the kind of job, e.g. "post" or "tag", while the warnings
+// and errors contain the location of the problem, if it's known.
+type buildReport struct {
+	Success  bool                     `json:"success"`
+	Error    string                   `json:"error,omitempty"`
+	Outputs  map[string]*reportOutput `json:"outputs"`
+	Bytes    int64                    `json:"bytes"`
+	Phases   []reportPhase            `json:"phases"`
+	Warnings []reportProblem          `json:"warnings"`
+	Errors   []reportProblem          `json:"errors"`
+
+	sink       output.Sink
+	phase      string
+	phaseStart time.Time
+}
+
+// reportOutput is the number of jobs in a kind that built or up to date, and
+// the number of files and bytes that written by the built ones.
+type reportOutput struct {
+	Built    int   `json:"built"`
+	UpToDate int   `json:"upToDate"`
+	Files    int   `json:"files"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// reportPhase is the duration of a phase in build, in milliseconds.
+type reportPhase struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+}
+
+// reportProblem is a warning or error that happened while building.
+type reportProblem struct {
+	Job     string `json:"job,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// newBuildReport returns an empty build report.
+func newBuildReport() *buildReport {
+	return &buildReport{
+		Outputs:    map[string]*reportOutput{},
+		Phases:     []reportPhase{},
+		Warnings:   []reportProblem{},
+		Errors:     []reportProblem{},
+		phaseStart: time.Now(),
+	}
+}
+
+// progress records the warnings in progress, and the duration
+// of the phase that ended when the next phase is started.
+func (r *buildReport) progress(p site.Progress) {
+	for _, err := range p.Warnings {
+		r.addWarning(err)
+	}
+
+	if p.Job == "" && p.Phase != r.phase {
+		r.endPhase()
+		r.phase = p.Phase
+	}
+}
+
+// endPhase records the duration of the current phase, if any.
+func (r *buildReport) endPhase() {
+	now := time.Now()
+	if r.phase != "" {
+		r.Phases = append(r.Phases, reportPhase{
+			Name:     r.phase,
+			Duration: float64(now.Sub(r.phaseStart)) / float64(time.Millisecond),
+		})
+	}
+
+	r.phase = ""
+	r.phaseStart = now
+}
+
+// addResult records the jobs in build result and the files that written by them.
+func (r *buildReport) addResult(result site.BuildResult) {
+	for _, job := range result.Jobs {
+		if !job.Built {
+			r.output(job.Kind).UpToDate++
+			continue
+		}
+
+		r.output(job.Kind).Built++
+		r.addFiles(job.Kind, job.Outputs)
+	}
+
+	if len(result.Images) > 0 {
+		r.addFiles("image", result.Images)
+	}
+}
+
+// addFiles records the files, relative to output dir, that written for a kind.
+func (r *buildReport) addFiles(kind string, files []string) {
+	output := r.output(kind)
+	for _, file := range files {
+		size, exist := r.fileSize(file)
+		if !exist {
+			continue
+		}
+
+		output.Files++
+		output.Bytes += size
+		r.Bytes += size
+	}
+}
+
+// fileSize returns the size of file that written into the output sink.
+func (r *buildReport) fileSize(file string) (int64, bool) {
+	switch sink := r.sink.(type) {
+	case *output.Dir:
+		info, err := os.Stat(fp.Join(sink.Path, fp.FromSlash(file)))
+		if err != nil {
+			return 0, false
+		}
+		return info.Size(), true
+
+	case *output.Memory:
+		content, exist := sink.File(file)
+		return int64(len(content)), exist
+
+	case *output.Archive:
+		content, exist := sink.File(file)
+		return int64(len(content)), exist
+	}
+
+	return 0, false
+}
+
+func (r *buildReport) output(kind string) *reportOutput {
+	output, exist := r.Outputs[kind]
+	if !exist {
+		output = &reportOutput{}
+		r.Outputs[kind] = output
+	}
+
+	return output
+}
+
+// addWarning records the error as warning.
+func (r *buildReport) addWarning(err error) {
+	r.Warnings = append(r.Warnings, newReportProblem(err))
+}
+
+// addError records the error, along with its location.
+func (r *buildReport) addError(err error) {
+	r.Errors = append(r.Errors, newReportProblem(err))
+}
+
+// finish ends the current phase, then marks the report
+// with the final error of the build, if any.
+func (r *buildReport) finish(err error) {
+	r.endPhase()
+	r.Success = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// print writes the report as JSON into stdout.
+func (r *buildReport) print() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(r)
+}
+
+// newReportProblem converts the error into problem for report. The location is taken
+// from the error in content, or from the template error that failed a job.
+func newReportProblem(err error) reportProblem {
+	switch e := err.(type) {
+	case parser.ContentError:
+		return reportProblem{
+			Path:    e.Path,
+			Line:    e.Line,
+			Column:  e.Column,
+			Message: e.Message,
+		}
+
+	case *site.JobError:
+		problem := reportProblem{
+			Job:     e.Job,
+			Message: e.Err.Error(),
+		}
+
+		idx := strings.Index(problem.Message, "template: ")
+		if idx >= 0 {
+			failure := parseTemplateError(errors.New(problem.Message[idx:]))
+			if failure.File != "" {
+				problem.Path = failure.File
+				problem.Line = failure.Line
+				problem.Column = failure.Column
+			}
+		}
+
+		return problem
+	}
+
+	return reportProblem{Message: err.Error()}
+}
@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
-	fp "path/filepath"
-	"strings"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
 
-	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/output"
 	"github.com/go-spook/spook/parser"
-	"github.com/go-spook/spook/renderer"
+	"github.com/go-spook/spook/site"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -20,275 +25,342 @@ func buildCmd() *cobra.Command {
 		Short:   "Build the static site",
 		Aliases: []string{"builder"},
 		Args:    cobra.NoArgs,
-		Run:     buildHandler,
+		RunE:    buildHandler,
 	}
 
-	cmd.Flags().StringP("output", "o", "public", "path to output directory")
+	cmd.Flags().StringP("output", "o", "public", "path to output directory or archive (.tar, .tar.gz, .tgz or .zip), or - for tar on stdout")
+	cmd.Flags().IntP("workers", "w", runtime.NumCPU(), "number of workers for rendering the site")
+	cmd.Flags().Bool("full", false, "force a clean rebuild instead of only rebuilding the changed files")
+	cmd.Flags().BoolP("keep-going", "k", false, "skip the invalid posts and pages instead of failing the build")
+	cmd.Flags().String("report", "", "print summary of the build in the specified format, i.e. json")
+	cmd.Flags().Bool("verify", false, "build the site twice in memory and make sure both builds are identical, without writing the output")
 
 	return cmd
 }
 
-func buildHandler(cmd *cobra.Command, args []string) {
-	// Make sure valid config file exists in current working dir
-	config, err := openConfigFile(true)
-	if err != nil {
-		cError.Println("Failed to open config file:", err)
-		return
+func buildHandler(cmd *cobra.Command, args []string) error {
+	// Make sure report format is supported
+	reportFormat, _ := cmd.Flags().GetString("report")
+	if reportFormat != "" && reportFormat != "json" {
+		return usageError(nil, "Report format %q is not supported, use json", reportFormat)
 	}
 
-	// Get working dir
-	rootDir, err := os.Getwd()
-	if err != nil {
-		cError.Println("Failed to get working dir:", err)
-		return
+	outputPath, _ := cmd.Flags().GetString("output")
+	if reportFormat != "" && outputPath == "-" {
+		return usageError(nil, "Report can't be printed when the output is written to stdout")
+	}
+
+	// Build the site, then print the report even if the build failed
+	report := newBuildReport()
+	err := buildSite(cmd, report)
+	if reportFormat == "" {
+		return err
+	}
+
+	report.finish(err)
+	printErr := report.print()
+	if err == nil && printErr != nil {
+		return ioError(printErr, "Failed to print build report")
 	}
 
-	// Get output directory and clean it
-	outputDir, _ := cmd.Flags().GetString("output")
-	err = os.MkdirAll(outputDir, os.ModePerm)
+	return err
+}
+
+func buildSite(cmd *cobra.Command, report *buildReport) error {
+	// Parse flags
+	outputPath, _ := cmd.Flags().GetString("output")
+	fullBuild, _ := cmd.Flags().GetBool("full")
+	keepGoing, _ := cmd.Flags().GetBool("keep-going")
+	verify, _ := cmd.Flags().GetBool("verify")
+	nWorkers, _ := cmd.Flags().GetInt("workers")
+	if nWorkers < 1 {
+		nWorkers = 1
+	}
+
+	// Get working dir and build time
+	rootDir, err := os.Getwd()
 	if err != nil {
-		cError.Println("Failed to create output dir:", err)
-		return
+		return ioError(err, "Failed to get working dir")
 	}
 
-	err = removeDirContents(outputDir)
+	buildTime, isReproducible, err := getBuildTime()
 	if err != nil {
-		cError.Println("Failed to clean output dir:", err)
-		return
+		return err
 	}
 
-	// Copy static directory if it exists
-	staticDir := fp.Join(rootDir, "static")
-	dstStaticDir := fp.Join(outputDir, "static")
+	// Cancel the build when it's interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	if dirExists(staticDir) {
-		err = copyDir(staticDir, dstStaticDir)
-		if err != nil {
-			cError.Println("Failed to copy static directory:", err)
-			return
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
+
+	// Load the site
+	opts := site.Options{
+		Workers:   nWorkers,
+		KeepGoing: keepGoing,
+		FullBuild: fullBuild,
+		Minimize:  true,
+		BuildTime: buildTime,
+		Progress: func(p site.Progress) {
+			logProgress(p, nWorkers)
+			report.progress(p)
+		},
 	}
 
-	// Copy theme directory
-	themeDir := fp.Join(rootDir, "theme", config.Theme)
-	themeItems, err := ioutil.ReadDir(themeDir)
+	if verify {
+		return verifyBuild(ctx, rootDir, opts, report)
+	}
+
+	s, err := site.LoadContext(ctx, rootDir, opts)
 	if err != nil {
-		cError.Println("Failed to read theme dir:", err)
-		return
+		return buildError(err, report)
 	}
 
-	for _, item := range themeItems {
-		if !item.IsDir() {
-			continue
+	// Build the site into output dir. If the output is an archive, the site is built
+	// in memory first, so the archive is only written if the build succeeded.
+	var sink output.Sink = output.NewDir(outputPath)
+	archive, isArchive := newArchive(outputPath)
+	if isArchive {
+		sink = archive
+		if isReproducible {
+			archive.ModTime = buildTime
 		}
+	}
 
-		srcDir := fp.Join(themeDir, item.Name())
-		dstDir := fp.Join(outputDir, item.Name())
-
-		err = copyDir(srcDir, dstDir)
-		if err != nil {
-			cError.Println("Failed to copy theme files:", err)
-			return
+	report.sink = sink
+	result, err := s.BuildTo(ctx, sink)
+	report.addResult(result)
+
+	nJobs, nBuilt := 0, 0
+	for _, job := range result.Jobs {
+		if job.Kind != "file" {
+			nJobs++
+			if job.Built {
+				nBuilt++
+			}
 		}
 	}
 
-	// Parse all posts and pages
-	psr := parser.Parser{
-		Config:  config,
-		RootDir: rootDir,
+	if nJobs > 0 {
+		logrus.Printf("Built %d of them, %d are up to date", nBuilt, nJobs-nBuilt)
 	}
 
-	parsedPosts, err := psr.ParsePosts()
 	if err != nil {
-		cError.Println("Failed to parse posts:", err)
-		return
+		return buildError(err, report)
 	}
 
-	pages, err := psr.ParsePages()
-	if err != nil {
-		cError.Println("Failed to parse pages:", err)
-		return
+	if isArchive {
+		return writeArchive(archive, outputPath)
 	}
 
-	// Create renderer
-	rd := renderer.Renderer{
-		Config:     config,
-		Pages:      pages,
-		Posts:      parsedPosts.Posts,
-		Tags:       parsedPosts.Tags,
-		Categories: parsedPosts.Categories,
-		RootDir:    rootDir,
-		Minimize:   true,
-	}
+	return nil
+}
 
-	// Build frontpage
-	logrus.Println("Building front page")
-	err = buildFrontPage(rd, outputDir)
-	if err != nil {
-		cError.Println("Failed to build front page:", err)
-		return
+// getBuildTime returns the time of build. If SOURCE_DATE_EPOCH environment variable is
+// set, the build time is taken from it so the build is reproducible, which is marked
+// by the returned bool. Otherwise, the build time is the current time.
+func getBuildTime() (time.Time, bool, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Now(), false, nil
 	}
 
-	// Build list of posts
-	logrus.Println("Building list of posts")
-	postsDir := fp.Join(outputDir, "posts")
-	err = buildList(rd, postsDir, renderer.DEFAULT, "")
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
 	if err != nil {
-		cError.Println("Failed to build main list:", err)
-		return
+		return time.Time{}, false, usageError(nil, "SOURCE_DATE_EPOCH %q is not a valid Unix timestamp", epoch)
 	}
 
-	// Build list of posts by category
-	logrus.Println("Building list of posts by category")
-	for _, category := range parsedPosts.Categories {
-		if category.Name == "" {
-			category.Name = "uncategorized"
-		}
-
-		categoryDir := fp.Join(outputDir, "category", category.Name)
+	return time.Unix(seconds, 0).UTC(), true, nil
+}
 
-		err = buildList(rd, categoryDir, renderer.CATEGORY, category.Name)
+// verifyBuild builds the site twice in memory, then makes sure both builds have
+// the same files, with the same content and modification time. The site is loaded
+// for each build, so parsing the contents is verified as well.
+func verifyBuild(ctx context.Context, rootDir string, opts site.Options, report *buildReport) error {
+	builds := []*output.Memory{}
+	for i := 1; i <= 2; i++ {
+		logrus.Printf("Building the site in memory (%d/2)", i)
+		s, err := site.LoadContext(ctx, rootDir, opts)
 		if err != nil {
-			cError.Printf("Failed to build list category \"%s\": %v\n:", category.Name, err)
-			return
+			return buildError(err, report)
 		}
-	}
 
-	// Build list of posts by tag
-	logrus.Println("Building list of posts by tag")
-	for _, tag := range parsedPosts.Tags {
-		tagDir := fp.Join(outputDir, "tag", tag.Name)
+		sink := output.NewMemory()
+		result, err := s.BuildTo(ctx, sink)
+		if i == 2 {
+			report.sink = sink
+			report.addResult(result)
+		}
 
-		err = buildList(rd, tagDir, renderer.TAG, tag.Name)
 		if err != nil {
-			cError.Printf("Failed to build list tag \"%s\": %v\n:", tag.Name, err)
-			return
+			return buildError(err, report)
 		}
-	}
 
-	// Build pages
-	logrus.Println("Building pages")
-	err = buildPages(rd, outputDir, pages)
-	if err != nil {
-		cError.Println("Failed to build pages:", err)
-		return
+		builds = append(builds, sink)
 	}
 
-	// Build posts
-	logrus.Println("Building posts")
-	err = buildPosts(rd, outputDir, parsedPosts.Posts)
-	if err != nil {
-		cError.Println("Failed to build posts:", err)
-		return
-	}
-}
-
-func buildFrontPage(rd renderer.Renderer, outputDir string) error {
-	frontPage, err := os.Create(fp.Join(outputDir, "index.html"))
-	if err != nil {
-		return fmt.Errorf("failed to create index file: %v", err)
+	differences := diffBuilds(builds[0], builds[1])
+	for _, difference := range differences {
+		printError(difference, nil)
+		report.addError(errors.New(difference))
 	}
 
-	err = rd.RenderFrontPage(frontPage)
-	if err != nil {
-		return fmt.Errorf("render failed: %v", err)
+	if len(differences) > 0 {
+		return newCmdError(ExitError, nil, "Build is not reproducible, found %d differences", len(differences))
 	}
 
+	logrus.Printf("Build is reproducible, both builds have the same %d files", len(builds[0].Names()))
 	return nil
 }
 
-func buildList(rd renderer.Renderer, outputDir string, listType renderer.ListType, groupName string) error {
-	err := os.MkdirAll(outputDir, os.ModePerm)
-	if err != nil {
-		return err
-	}
+// diffBuilds returns the differences between files in both builds, sorted by their name.
+func diffBuilds(first, second *output.Memory) []string {
+	names := append(first.Names(), second.Names()...)
+	sort.Strings(names)
 
-	for i := 0; ; i++ {
-		fileName := "index.html"
-		if i > 0 {
-			fileName = fmt.Sprintf("%d.html", i)
+	differences := []string{}
+	for i, name := range names {
+		if i > 0 && name == names[i-1] {
+			continue
 		}
-		fileName = fp.Join(outputDir, fileName)
 
-		f, err := os.Create(fileName)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %v", fileName, err)
+		firstContent, inFirst := first.File(name)
+		secondContent, inSecond := second.File(name)
+		firstModTime, _ := first.ModTime(name)
+		secondModTime, _ := second.ModTime(name)
+
+		switch {
+		case !inSecond:
+			differences = append(differences, fmt.Sprintf("%s only exists in the first build", name))
+		case !inFirst:
+			differences = append(differences, fmt.Sprintf("%s only exists in the second build", name))
+		case !bytes.Equal(firstContent, secondContent):
+			differences = append(differences, fmt.Sprintf("%s has different content", name))
+		case !firstModTime.Equal(secondModTime):
+			differences = append(differences, fmt.Sprintf("%s has different modification time", name))
 		}
+	}
 
-		nPosts, err := rd.RenderList(listType, groupName, i, f)
-		f.Close()
-		if err != nil {
-			return fmt.Errorf("failed to build list of posts: %v", err)
-		}
+	return differences
+}
 
-		if nPosts == -1 {
-			os.Remove(fileName)
-			break
-		}
+// newArchive returns the archive sink for output path, which is either an archive
+// file or "-" for tar on stdout. Returns false if the output is a directory.
+func newArchive(outputPath string) (*output.Archive, bool) {
+	if outputPath == "-" {
+		return output.NewArchive(output.Tar), true
 	}
 
-	return nil
-}
+	format, isArchive := output.ArchiveFormatOf(outputPath)
+	if !isArchive {
+		return nil, false
+	}
 
-func buildPages(rd renderer.Renderer, outputDir string, pages []model.Page) error {
-	for _, page := range pages {
-		page.Path = strings.TrimPrefix(page.Path, "/")
+	return output.NewArchive(format), true
+}
 
-		dstDir := fp.Join(outputDir, page.Path)
-		err := copyDir(page.Path, dstDir, "_index.md")
+// writeArchive writes the built site as archive into output path, or into stdout.
+func writeArchive(archive *output.Archive, outputPath string) error {
+	if outputPath == "-" {
+		_, err := archive.WriteTo(os.Stdout)
 		if err != nil {
-			return fmt.Errorf("failed to copy files for %s: %v", page.Path, err)
+			return ioError(err, "Failed to write archive to stdout")
 		}
+		return nil
+	}
 
-		dstIndexPath := fp.Join(dstDir, "index.html")
-		f, err := os.Create(dstIndexPath)
-		if err != nil {
-			return fmt.Errorf("failed to create index file for %s: %v", page.Path, err)
-		}
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return ioError(err, "Failed to create archive")
+	}
+	defer f.Close()
 
-		err = rd.RenderPage(page, f)
-		f.Close()
-		if err != nil {
-			return fmt.Errorf("failed to build %s: %v", page.Path, err)
-		}
+	size, err := archive.WriteTo(f)
+	if err != nil {
+		return ioError(err, "Failed to write archive")
 	}
 
+	err = f.Close()
+	if err != nil {
+		return ioError(err, "Failed to write archive")
+	}
+
+	logrus.Printf("Written %d files into %s (%d bytes)", len(archive.Names()), outputPath, size)
 	return nil
 }
 
-func buildPosts(rd renderer.Renderer, outputDir string, posts []model.Post) error {
-	for i, post := range posts {
-		post.Path = strings.TrimPrefix(post.Path, "/")
-
-		dstDir := fp.Join(outputDir, post.Path)
-		err := copyDir(post.Path, dstDir, "_index.md")
-		if err != nil {
-			return fmt.Errorf("failed to copy files for %s: %v", post.Path, err)
+// logProgress logs the progress of loading and building site,
+// including the errors in invalid contents that skipped.
+func logProgress(p site.Progress, nWorkers int) {
+	if len(p.Warnings) > 0 {
+		for _, err := range p.Warnings {
+			printWarning("", err)
 		}
 
-		dstIndexPath := fp.Join(dstDir, "index.html")
-		f, err := os.Create(dstIndexPath)
-		if err != nil {
-			return fmt.Errorf("failed to create index file for %s: %v", post.Path, err)
-		}
+		nContents := len(p.Warnings.Paths())
+		printWarning(fmt.Sprintf("Skipping %d invalid contents with %d errors", nContents, len(p.Warnings)), nil)
+		return
+	}
+
+	switch {
+	case p.Job == "" && p.Phase == "render":
+		logrus.Printf("Building %d pages, posts and lists using %d workers", p.Total, nWorkers)
+	case p.Job == "" && p.Phase == "images":
+		logrus.Println("Writing resized images")
+	case p.Job == "":
+		logrus.Debugf("Starting phase %s", p.Phase)
+	case p.Skipped:
+		logrus.Debugf("Skipping %s, it's up to date", p.Job)
+	case p.Err == nil:
+		logrus.Debugf("Finished %s of %s (%d/%d)", p.Phase, p.Job, p.Done, p.Total)
+	}
+}
 
-		newerPost := model.Post{}
-		olderPost := model.Post{}
+// buildError prints the errors in contents or jobs that failed the build,
+// records them into the report, then returns the command error for it.
+func buildError(err error, report *buildReport) error {
+	if errors.Is(err, context.Canceled) {
+		return newCmdError(ExitError, nil, "Build is canceled")
+	}
 
-		if i > 0 {
-			newerPost = posts[i-1]
+	var siteErr *site.Error
+	if !errors.As(err, &siteErr) {
+		return newCmdError(ExitError, err, "Build failed")
+	}
+
+	code := siteExitCode(siteErr.Kind)
+	message := capitalize(siteErr.Message)
+
+	switch errs := siteErr.Err.(type) {
+	case parser.ContentErrors:
+		for _, err := range errs {
+			printError("", err)
+			report.addError(err)
 		}
+		return newCmdError(code, nil, "%s", message)
 
-		if i < len(posts)-1 {
-			olderPost = posts[i+1]
+	case site.JobErrors:
+		prefix := "Failed to build"
+		if siteErr.Kind == site.ContentError {
+			prefix = "Failed to render"
 		}
 
-		err = rd.RenderPost(post, olderPost, newerPost, f)
-		f.Close()
-		if err != nil {
-			return fmt.Errorf("failed to build %s: %v", post.Path, err)
+		for _, err := range errs {
+			printError(prefix, err)
+			report.addError(err)
 		}
+		return newCmdError(code, nil, "%s", message)
 	}
 
-	return nil
+	return newCmdError(code, siteErr.Err, "%s", message)
 }
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-spook/spook/site"
+)
+
+// Exit codes that returned by spook when a command is failed.
+const (
+	ExitOK            = 0
+	ExitError         = 1
+	ExitConfigError   = 2
+	ExitContentError  = 3
+	ExitTemplateError = 4
+	ExitIOError       = 5
+)
+
+// cmdError is an error that returned by command handler. Its message is printed
+// to user as it is, while its code is used as the exit code of spook.
+type cmdError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+// Error returns the message of the error, followed by its cause if any.
+func (e *cmdError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+// newCmdError returns a new command error with the specified exit code.
+func newCmdError(code int, err error, format string, args ...interface{}) error {
+	return &cmdError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+// usageError returns error caused by invalid usage, e.g. invalid argument.
+func usageError(err error, format string, args ...interface{}) error {
+	return newCmdError(ExitError, err, format, args...)
+}
+
+// configError returns error caused by missing or invalid config file.
+func configError(err error, format string, args ...interface{}) error {
+	return newCmdError(ExitConfigError, err, format, args...)
+}
+
+// contentError returns error caused by invalid posts or pages.
+func contentError(err error, format string, args ...interface{}) error {
+	return newCmdError(ExitContentError, err, format, args...)
+}
+
+// templateError returns error caused by invalid theme or its templates.
+func templateError(err error, format string, args ...interface{}) error {
+	return newCmdError(ExitTemplateError, err, format, args...)
+}
+
+// ioError returns error caused by failure while reading or writing files.
+func ioError(err error, format string, args ...interface{}) error {
+	return newCmdError(ExitIOError, err, format, args...)
+}
+
+// ExitCode returns the exit code for error that returned by command.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	if cmdErr, ok := err.(*cmdError); ok {
+		return cmdErr.Code
+	}
+
+	return ExitError
+}
+
+// siteExitCode returns the exit code for the kind of error that returned by site.
+func siteExitCode(kind site.ErrorKind) int {
+	switch kind {
+	case site.ConfigError:
+		return ExitConfigError
+	case site.ContentError:
+		return ExitContentError
+	case site.TemplateError:
+		return ExitTemplateError
+	case site.IOError:
+		return ExitIOError
+	default:
+		return ExitError
+	}
+}
+
+// capitalize changes the first letter of message into uppercase.
+func capitalize(message string) string {
+	if message == "" {
+		return message
+	}
+
+	return strings.ToUpper(message[:1]) + message[1:]
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/styles"
+	"github.com/go-spook/spook/renderer"
+	"github.com/spf13/cobra"
+)
+
+func genChromaCSSCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chroma-css",
+		Short: "Generate stylesheet for syntax highlighting",
+		Long: "Generate stylesheet for syntax highlighting in code block. " +
+			"If --style flag is not used, it will use the style from config file. " +
+			"If --output flag is not used, the stylesheet will be printed to stdout.",
+		Args: cobra.NoArgs,
+		RunE: genChromaCSSHandler,
+	}
+
+	cmd.Flags().StringP("style", "s", "", "name of highlighting style")
+	cmd.Flags().StringP("output", "o", "", "path to output file")
+
+	return cmd
+}
+
+func genChromaCSSHandler(cmd *cobra.Command, args []string) error {
+	// Read flags
+	styleName, _ := cmd.Flags().GetString("style")
+	outputPath, _ := cmd.Flags().GetString("output")
+
+	// If config file exists, use the highlight config from it
+	config, err := openConfigFile(false)
+	if err != nil && !os.IsNotExist(err) {
+		return configError(err, "Failed to open config file")
+	}
+	highlightConfig := config.Markup.Highlight
+
+	if styleName != "" {
+		highlightConfig.Style = styleName
+	}
+
+	// Make sure the style exists
+	if highlightConfig.Style != "" {
+		if _, exist := styles.Registry[highlightConfig.Style]; !exist {
+			names := styles.Names()
+			sort.Strings(names)
+			return usageError(nil, "Style %s is not exist, available styles: %s",
+				highlightConfig.Style, strings.Join(names, ", "))
+		}
+	}
+
+	// Write the stylesheet
+	var w io.Writer = os.Stdout
+	if outputPath != "" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			return ioError(err, "Failed to create output file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = renderer.WriteHighlightCSS(w, highlightConfig)
+	if err != nil {
+		return ioError(err, "Failed to write stylesheet")
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func genCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen",
+		Short: "Generate helper files for the site",
+	}
+
+	cmd.AddCommand(genChromaCSSCmd())
+	return cmd
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/fatih/color"
+	"github.com/go-spook/spook/parser"
+	"github.com/go-spook/spook/site"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// logFormat is the format of the log, either "text" or "json".
+var logFormat = "text"
+
+// setupLogger configures the logger following the global flags.
+func setupLogger(cmd *cobra.Command) error {
+	format, _ := cmd.Flags().GetString("log-format")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	switch format {
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return usageError(nil, "Log format %q is not supported, use text or json", format)
+	}
+
+	if quiet && verbose {
+		return usageError(nil, "Flag --quiet can't be used along with --verbose")
+	}
+
+	switch {
+	case quiet:
+		logrus.SetLevel(logrus.ErrorLevel)
+	case verbose:
+		logrus.SetLevel(logrus.DebugLevel)
+	default:
+		logrus.SetLevel(logrus.InfoLevel)
+	}
+
+	logFormat = format
+	return nil
+}
+
+// printError prints the message followed by the error in red. If the log format is
+// JSON, it's logged as JSON instead, with the location of error as its fields.
+func printError(message string, err error) {
+	printLog(logrus.ErrorLevel, cError, message, err)
+}
+
+// printWarning prints the message followed by the error in yellow,
+// unless it's quieted. Like printError, it follows the log format.
+func printWarning(message string, err error) {
+	printLog(logrus.WarnLevel, cWarning, message, err)
+}
+
+func printLog(level logrus.Level, c *color.Color, message string, err error) {
+	if !logrus.IsLevelEnabled(level) {
+		return
+	}
+
+	if logFormat != "json" {
+		switch {
+		case err == nil:
+			c.Fprintln(color.Error, message)
+		case message == "":
+			c.Fprintln(color.Error, err)
+		default:
+			c.Fprintln(color.Error, message, err)
+		}
+		return
+	}
+
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	switch e := err.(type) {
+	case parser.ContentError:
+		entry = entry.WithField("path", e.Path)
+		if e.Line > 0 {
+			entry = entry.WithFields(logrus.Fields{
+				"line":   e.Line,
+				"column": e.Column,
+			})
+		}
+		err = errors.New(e.Message)
+	case *site.JobError:
+		entry = entry.WithField("job", e.Job)
+		err = e.Err
+	}
+
+	switch {
+	case err == nil:
+		entry.Log(level, message)
+	case message == "":
+		entry.Log(level, err.Error())
+	default:
+		entry.Log(level, message+" "+err.Error())
+	}
+}
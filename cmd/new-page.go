@@ -15,16 +15,15 @@ func newPageCmd() *cobra.Command {
 		Use:   "page [title]",
 		Short: "Create a new page with specified title",
 		Args:  cobra.ExactArgs(1),
-		Run:   newPageHandler,
+		RunE:  newPageHandler,
 	}
 }
 
-func newPageHandler(cmd *cobra.Command, args []string) {
+func newPageHandler(cmd *cobra.Command, args []string) error {
 	// Make sure valid config file exists in current working dir
 	_, err := openConfigFile(false)
 	if err != nil {
-		cError.Println("Failed to open config file:", err)
-		return
+		return configError(err, "Failed to open config file")
 	}
 
 	// Create unique directory name with max length 80 character
@@ -40,8 +39,7 @@ func newPageHandler(cmd *cobra.Command, args []string) {
 	indexPath := fp.Join(pageDir, "_index.md")
 	indexFile, err := os.Create(indexPath)
 	if err != nil {
-		cError.Println("Failed to create index file:", err)
-		return
+		return ioError(err, "Failed to create index file")
 	}
 	defer indexFile.Close()
 
@@ -58,4 +56,6 @@ func newPageHandler(cmd *cobra.Command, args []string) {
 	// Finish
 	fmt.Print("Congratulations! Your new page is created in ")
 	cBold.Println(pageDir)
+
+	return nil
 }
@@ -16,16 +16,15 @@ func newPostCmd() *cobra.Command {
 		Use:   "post [title]",
 		Short: "Create a new post with specified title",
 		Args:  cobra.ExactArgs(1),
-		Run:   newPostHandler,
+		RunE:  newPostHandler,
 	}
 }
 
-func newPostHandler(cmd *cobra.Command, args []string) {
+func newPostHandler(cmd *cobra.Command, args []string) error {
 	// Make sure valid config file exists in current working dir
 	config, err := openConfigFile(false)
 	if err != nil {
-		cError.Println("Failed to open config file:", err)
-		return
+		return configError(err, "Failed to open config file")
 	}
 
 	// Get current time
@@ -47,8 +46,7 @@ func newPostHandler(cmd *cobra.Command, args []string) {
 	indexPath := fp.Join(postDir, "_index.md")
 	indexFile, err := os.Create(indexPath)
 	if err != nil {
-		cError.Println("Failed to create index file:", err)
-		return
+		return ioError(err, "Failed to create index file")
 	}
 	defer indexFile.Close()
 
@@ -71,4 +69,6 @@ func newPostHandler(cmd *cobra.Command, args []string) {
 	// Finish
 	fmt.Print("Congratulations! Your new post is created in ")
 	cBold.Println(postDir)
+
+	return nil
 }
@@ -17,7 +17,7 @@ func newSiteCmd() *cobra.Command {
 		Use:   "site [path]",
 		Short: "Create a skeleton for new website and put it inside the provided directory",
 		Args:  cobra.ExactArgs(1),
-		Run:   newSiteHandler,
+		RunE:  newSiteHandler,
 	}
 
 	cmd.Flags().Bool("force", false, "force init inside non-empty directory")
@@ -25,7 +25,7 @@ func newSiteCmd() *cobra.Command {
 	return cmd
 }
 
-func newSiteHandler(cmd *cobra.Command, args []string) {
+func newSiteHandler(cmd *cobra.Command, args []string) error {
 	// Read arguments
 	rootDir := args[0]
 	rootDir, _ = fp.Abs(rootDir)
@@ -36,8 +36,7 @@ func newSiteHandler(cmd *cobra.Command, args []string) {
 
 	// Make sure target dir is empty
 	if !isEmpty(rootDir) && !isForced {
-		cError.Printf("Directory %s already exists and not empty\n", rootDir)
-		return
+		return usageError(nil, "Directory %s already exists and not empty", rootDir)
 	}
 
 	// Get website name and base url from user
@@ -51,8 +50,7 @@ func newSiteHandler(cmd *cobra.Command, args []string) {
 	title := scanner.Text()
 	title = strings.TrimSpace(title)
 	if title == "" {
-		cError.Println("Website title must not empty")
-		return
+		return usageError(nil, "Website title must not empty")
 	}
 
 	cBold.Print("Website owner : ")
@@ -71,18 +69,22 @@ func newSiteHandler(cmd *cobra.Command, args []string) {
 	configPath := fp.Join(rootDir, "config.toml")
 	configFile, err := os.Create(configPath)
 	if err != nil {
-		cError.Println("Failed to create config file:", err)
-		return
+		return ioError(err, "Failed to create config file")
 	}
 	defer configFile.Close()
 
 	err = toml.NewEncoder(configFile).Encode(&model.Config{
 		Title:      title,
 		Owner:      owner,
-		Pagination: 10})
+		Pagination: 10,
+		Markup: model.MarkupConfig{
+			Highlight: model.HighlightConfig{
+				Style:    "monokai",
+				TabWidth: 4,
+			},
+		}})
 	if err != nil {
-		cError.Println("Failed to write config file:", err)
-		return
+		return ioError(err, "Failed to write config file")
 	}
 
 	// Finish
@@ -90,4 +92,6 @@ func newSiteHandler(cmd *cobra.Command, args []string) {
 	fmt.Print("Congratulations! Your new Spook site is created in ")
 	cBold.Println(rootDir)
 	fmt.Println("Don't forget to check your config file and choose your theme.")
+
+	return nil
 }
@@ -5,6 +5,9 @@ import (
 	"os"
 	fp "path/filepath"
 
+	"github.com/BurntSushi/toml"
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/theme"
 	"github.com/spf13/cobra"
 )
 
@@ -13,11 +16,11 @@ func newThemeCmd() *cobra.Command {
 		Use:   "theme [name]",
 		Short: "Create a skeleton for new theme",
 		Args:  cobra.ExactArgs(1),
-		Run:   newThemeHandler,
+		RunE:  newThemeHandler,
 	}
 }
 
-func newThemeHandler(cmd *cobra.Command, args []string) {
+func newThemeHandler(cmd *cobra.Command, args []string) error {
 	// Read arguments
 	name := args[0]
 	themeDir := fp.Join("theme", name)
@@ -26,8 +29,7 @@ func newThemeHandler(cmd *cobra.Command, args []string) {
 	// Make sure valid config file exists in current working dir
 	_, err := openConfigFile(false)
 	if err != nil {
-		cError.Println("Failed to open config file:", err)
-		return
+		return configError(err, "Failed to open config file")
 	}
 
 	// Create new directory for theme
@@ -35,8 +37,7 @@ func newThemeHandler(cmd *cobra.Command, args []string) {
 
 	// Make sure target dir is empty
 	if !isEmpty(themeDir) {
-		cError.Printf("%s already exists and not empty\n", themeDir)
-		return
+		return usageError(nil, "%s already exists and not empty", themeDir)
 	}
 
 	// Create directories and files
@@ -50,7 +51,25 @@ func newThemeHandler(cmd *cobra.Command, args []string) {
 	createFile(fp.Join(themeDir, "post.html"))
 	createFile(fp.Join(themeDir, "404.html"))
 
+	// Write theme's manifest
+	manifestFile, err := os.Create(fp.Join(themeDir, theme.ManifestFile))
+	if err != nil {
+		return ioError(err, "Failed to create manifest file")
+	}
+	defer manifestFile.Close()
+
+	err = toml.NewEncoder(manifestFile).Encode(&model.Theme{
+		Name:       name,
+		MinVersion: model.Version,
+		Templates:  []string{"frontpage.html", "list.html", "page.html", "post.html"},
+	})
+	if err != nil {
+		return ioError(err, "Failed to write manifest file")
+	}
+
 	// Finish
 	fmt.Print("Congratulations! Your new theme is created in ")
 	cBold.Println(themeDir)
+
+	return nil
 }
@@ -6,17 +6,45 @@ import (
 )
 
 var (
-	cBold  = color.New(color.Bold)
-	cError = color.New(color.FgHiRed)
+	cBold    = color.New(color.Bold)
+	cError   = color.New(color.FgHiRed)
+	cWarning = color.New(color.FgHiYellow)
 )
 
 // SpookCmd creates new command for spook
 func SpookCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "spook",
-		Short: "Simple, minimalist and opinionated static site generator",
+		Use:           "spook",
+		Short:         "Simple, minimalist and opinionated static site generator",
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			err := setupLogger(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Once the arguments are valid, the error is not caused
+			// by wrong usage, so there is no need to print the usage.
+			cmd.SilenceUsage = true
+			return nil
+		},
 	}
 
-	cmd.AddCommand(newCmd(), serveCmd(), buildCmd())
+	cmd.PersistentFlags().String("log-format", "text", "format of the log, either text or json")
+	cmd.PersistentFlags().BoolP("quiet", "q", false, "only print the errors")
+	cmd.PersistentFlags().BoolP("verbose", "v", false, "print the detail of every step")
+
+	cmd.AddCommand(newCmd(), serveCmd(), buildCmd(), themeCmd(), genCmd())
 	return cmd
 }
+
+// Execute runs spook command, prints the error if any,
+// then returns the exit code for the error.
+func Execute() int {
+	err := SpookCmd().Execute()
+	if err != nil {
+		printError("", err)
+	}
+
+	return ExitCode(err)
+}
@@ -18,7 +18,7 @@ func serveCmd() *cobra.Command {
 			"If --port flag is not used, it will use port 8080 by default.",
 		Aliases: []string{"serve"},
 		Args:    cobra.NoArgs,
-		Run:     serveHandler,
+		RunE:    serveHandler,
 	}
 
 	cmd.Flags().IntP("port", "p", 8080, "Port that used by webserver")
@@ -26,29 +26,28 @@ func serveCmd() *cobra.Command {
 	return cmd
 }
 
-func serveHandler(cmd *cobra.Command, args []string) {
+func serveHandler(cmd *cobra.Command, args []string) error {
 	// Parse flags
 	port, _ := cmd.Flags().GetInt("port")
 
 	// Get working dir
 	rootDir, err := os.Getwd()
 	if err != nil {
-		cError.Println("Failed to get working dir:", err)
-		return
+		return ioError(err, "Failed to get working dir")
 	}
 
 	// Make sure valid config file exists in current working dir
 	config, err := openConfigFile(true)
 	if err != nil {
-		cError.Println("Failed to open config file:", err)
-		return
+		return configError(err, "Failed to open config file")
 	}
 
 	// Start server
 	logrus.Printf("Serve spook in :%d\n", port)
 	err = webserver.Start(rootDir, config, port)
 	if err != nil {
-		cError.Println("Failed to start server:", err)
-		return
+		return ioError(err, "Failed to start server")
 	}
+
+	return nil
 }
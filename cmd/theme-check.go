@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	fp "path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/parser"
+	"github.com/go-spook/spook/renderer"
+	"github.com/go-spook/spook/theme"
+	"github.com/spf13/cobra"
+)
+
+// scenarioTemplates is the templates that executed while rendering
+// the sample posts, pages and lists.
+var scenarioTemplates = map[string]struct{}{
+	"frontpage.html":         {},
+	"list.html":              {},
+	"page.html":              {},
+	"post.html":              {},
+	"render-link.html":       {},
+	"render-image.html":      {},
+	"shortcode-gallery.html": {},
+}
+
+var rxTemplateError = regexp.MustCompile(`template: ([^:]+):(\d+):(?:(\d+):)?\s*(?:executing "[^"]*" at <([^>]*)>:\s*)?(.*)$`)
+
+// templateFailure is a failure that happened while executing a template.
+type templateFailure struct {
+	File     string
+	Line     int
+	Column   int
+	Field    string
+	Message  string
+	Scenario string
+}
+
+func themeCheckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check [name]",
+		Short: "Execute templates of a theme against sample data, or the active theme if name is not specified",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  themeCheckHandler,
+	}
+}
+
+func themeCheckHandler(cmd *cobra.Command, args []string) error {
+	// Make sure valid config file exists in current working dir
+	config, err := openConfigFile(false)
+	if err != nil {
+		return configError(err, "Failed to open config file")
+	}
+
+	// Get working dir
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return ioError(err, "Failed to get working dir")
+	}
+
+	// Read arguments
+	name := config.Theme
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	if name == "" {
+		return configError(nil, "No theme specified in config file")
+	}
+
+	// Make sure theme is valid
+	siteFS := os.DirFS(rootDir)
+	err = theme.Validate(siteFS, name)
+	if err != nil {
+		return templateError(err, "Invalid theme")
+	}
+
+	themeChain, err := theme.Chain(siteFS, name)
+	if err != nil {
+		return templateError(err, "Failed to open theme")
+	}
+
+	// Create synthetic site in temporary directory
+	siteDir, err := ioutil.TempDir("", "spook-check-")
+	if err != nil {
+		return ioError(err, "Failed to create temporary dir")
+	}
+	defer os.RemoveAll(siteDir)
+
+	config.Theme = name
+	config.Pagination = 2
+	err = createSampleSite(rootDir, siteDir, themeChain)
+	if err != nil {
+		return ioError(err, "Failed to create sample site")
+	}
+
+	// Execute all templates
+	failures, err := checkTheme(siteDir, config)
+	if err != nil {
+		return templateError(err, "Failed to check theme")
+	}
+
+	if len(failures) == 0 {
+		fmt.Println("All templates executed successfully")
+		return nil
+	}
+
+	for _, failure := range failures {
+		file := failure.File
+		if th, found := theme.FindFile(themeChain, file); found {
+			file = path.Join(th.Path, file)
+		}
+
+		location := fmt.Sprintf("%s:%d", file, failure.Line)
+		if failure.Column > 0 {
+			location += fmt.Sprintf(":%d", failure.Column)
+		}
+
+		cBold.Print(location, ": ")
+		if failure.Field != "" {
+			fmt.Printf("<%s> ", failure.Field)
+		}
+		fmt.Printf("%s (%s)\n", failure.Message, failure.Scenario)
+	}
+
+	return templateError(nil, "Found %d failure(s) in theme %s", len(failures), name)
+}
+
+// checkTheme renders every page type of the site in siteDir, including every pagination
+// state, then executes the other templates in theme chain, e.g. 404.html, using the layout
+// of the site. Returns the failures that happened.
+func checkTheme(siteDir string, config model.Config) ([]templateFailure, error) {
+	// Parse sample posts and pages
+	psr := parser.Parser{
+		Config: config,
+		FS:     os.DirFS(siteDir),
+	}
+
+	parsedPosts, err := psr.ParsePosts()
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := psr.ParsePages()
+	if err != nil {
+		return nil, err
+	}
+
+	rd := renderer.Renderer{
+		Config:     config,
+		Pages:      pages,
+		Posts:      parsedPosts.Posts,
+		Tags:       parsedPosts.Tags,
+		Categories: parsedPosts.Categories,
+		FS:         psr.FS,
+	}
+
+	// Render each scenario and collect the unique failures
+	failures := []templateFailure{}
+	visited := map[string]struct{}{}
+	addFailure := func(scenario string, err error) {
+		if err == nil {
+			return
+		}
+
+		failure := parseTemplateError(err)
+		failure.Scenario = scenario
+
+		key := fmt.Sprintf("%s:%d:%d:%s", failure.File, failure.Line, failure.Column, failure.Message)
+		if _, exist := visited[key]; exist {
+			return
+		}
+
+		visited[key] = struct{}{}
+		failures = append(failures, failure)
+	}
+
+	// Convert the content of posts and pages, which uses the hook templates. If it
+	// failed, convert it without the hooks so the other templates still can be checked.
+	parseContent := func(scenario string, contentPath string, content *model.Content) *model.Content {
+		parsed, err := psr.ParseContent(contentPath, content.Markdown, rd.ConvertMarkdown)
+		if err != nil {
+			addFailure(scenario, err)
+			parsed, err = psr.ParseContent(contentPath, content.Markdown, nil)
+		}
+
+		if err != nil {
+			return content
+		}
+		return parsed
+	}
+
+	for i, post := range parsedPosts.Posts {
+		scenario := fmt.Sprintf("content of post %q", post.Title)
+		parsedPosts.Posts[i].Content = parseContent(scenario, post.Path, post.Content)
+		if post.Excerpt == "" {
+			parsedPosts.Posts[i].Excerpt = parsedPosts.Posts[i].Content.Summary
+		}
+	}
+
+	for i, page := range pages {
+		scenario := fmt.Sprintf("content of page %q", page.Title)
+		pages[i].Content = parseContent(scenario, page.Path, page.Content)
+		if page.Excerpt == "" {
+			pages[i].Excerpt = pages[i].Content.Summary
+		}
+	}
+
+	addFailure("front page", rd.RenderFrontPage(ioutil.Discard))
+
+	renderList := func(listType renderer.ListType, groupName string, label string) {
+		for i := 1; ; i++ {
+			scenario := fmt.Sprintf("%s, page %d", label, i)
+			nPosts, err := rd.RenderList(listType, groupName, i, ioutil.Discard)
+			addFailure(scenario, err)
+			if err != nil || nPosts == -1 {
+				break
+			}
+		}
+	}
+
+	renderList(renderer.DEFAULT, "", "list of posts")
+
+	for _, category := range parsedPosts.Categories {
+		if category.Name == "" {
+			category.Name = "uncategorized"
+		}
+
+		label := fmt.Sprintf("list of category %q", category.Name)
+		renderList(renderer.CATEGORY, category.Name, label)
+	}
+
+	for _, tag := range parsedPosts.Tags {
+		label := fmt.Sprintf("list of tag %q", tag.Name)
+		renderList(renderer.TAG, tag.Name, label)
+	}
+
+	for _, page := range pages {
+		scenario := fmt.Sprintf("page %q", page.Title)
+		addFailure(scenario, rd.RenderPage(page, ioutil.Discard))
+	}
+
+	posts := parsedPosts.Posts
+	for i, post := range posts {
+		newerPost := model.Post{}
+		olderPost := model.Post{}
+
+		if i > 0 {
+			newerPost = posts[i-1]
+		}
+
+		if i < len(posts)-1 {
+			olderPost = posts[i+1]
+		}
+
+		scenario := fmt.Sprintf("post %q", post.Title)
+		addFailure(scenario, rd.RenderPost(post, olderPost, newerPost, ioutil.Discard))
+	}
+
+	// Execute the remaining templates, which are not rendered by any scenario above
+	chain, err := theme.Chain(rd.FS, config.Theme)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range theme.Templates(chain) {
+		if _, rendered := scenarioTemplates[name]; rendered {
+			continue
+		}
+
+		scenario := fmt.Sprintf("template %s", name)
+		addFailure(scenario, rd.RenderTemplate(name, ioutil.Discard))
+	}
+
+	return failures, nil
+}
+
+// parseTemplateError extracts the file, line and field from error
+// that returned by html/template.
+func parseTemplateError(err error) templateFailure {
+	msg := strings.TrimSpace(err.Error())
+	parts := rxTemplateError.FindStringSubmatch(msg)
+	if parts == nil {
+		return templateFailure{Message: msg}
+	}
+
+	line, _ := strconv.Atoi(parts[2])
+	column, _ := strconv.Atoi(parts[3])
+
+	return templateFailure{
+		File:    parts[1],
+		Line:    line,
+		Column:  column,
+		Field:   parts[4],
+		Message: parts[5],
+	}
+}
+
+// createSampleSite creates a site filled with fake posts and pages, that
+// covers every combination of category, tag, thumbnail and pagination.
+// The themes in chain are copied from the site in root dir.
+func createSampleSite(rootDir string, siteDir string, themeChain []model.Theme) error {
+	// Copy theme and its parents
+	for _, th := range themeChain {
+		dstDir := fp.Join(siteDir, "theme", path.Base(th.Path))
+		err := copyDir(fp.Join(rootDir, fp.FromSlash(th.Path)), dstDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create sample posts
+	type samplePost struct {
+		Category  string
+		Tags      []string
+		Thumbnail bool
+	}
+
+	samplePosts := []samplePost{
+		{Category: "Programming", Tags: []string{"go", "web"}, Thumbnail: true},
+		{Category: "Programming", Tags: []string{"go"}},
+		{Category: "", Tags: []string{}, Thumbnail: true},
+		{Category: "Life", Tags: []string{"web", "story"}},
+		{Category: "Programming", Tags: []string{"go", "story"}},
+	}
+
+	for i, sample := range samplePosts {
+		metadata := model.Post{
+			Title:     fmt.Sprintf("Sample post %d", i+1),
+			CreatedAt: fmt.Sprintf("2019-01-%02d 10:00:00 +0700", i+1),
+			Category:  sample.Category,
+			Tags:      sample.Tags,
+			Author:    "Sample Author",
+		}
+
+		if i%2 == 0 {
+			metadata.Excerpt = "This is an excerpt that written manually."
+		}
+
+		postDir := fp.Join(siteDir, "post", fmt.Sprintf("2019-01-%02d-sample-post-%d", i+1, i+1))
+		err := createSampleContent(postDir, &metadata, sample.Thumbnail)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create sample pages
+	for i := 0; i < 2; i++ {
+		metadata := model.Page{
+			Title: fmt.Sprintf("Sample page %d", i+1),
+		}
+
+		pageDir := fp.Join(siteDir, "page", fmt.Sprintf("sample-page-%d", i+1))
+		err := createSampleContent(pageDir, &metadata, i == 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createSampleContent writes index file with specified metadata
+// and sample markdown into dir. If needed, also creates the thumbnail.
+func createSampleContent(dir string, metadata interface{}, withThumbnail bool) error {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	indexFile, err := os.Create(fp.Join(dir, "_index.md"))
+	if err != nil {
+		return err
+	}
+	defer indexFile.Close()
+
+	fmt.Fprintln(indexFile, "+++")
+	err = toml.NewEncoder(indexFile).Encode(metadata)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(indexFile, "+++")
+	fmt.Fprint(indexFile, sampleMarkdown)
+
+	if !withThumbnail {
+		return indexFile.Sync()
+	}
+
+	thumbnailFile, err := os.Create(fp.Join(dir, "_thumbnail.png"))
+	if err != nil {
+		return err
+	}
+	defer thumbnailFile.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	err = png.Encode(thumbnailFile, img)
+	if err != nil {
+		return err
+	}
+
+	// Also create image for the gallery shortcode
+	galleryFile, err := os.Create(fp.Join(dir, "gallery.png"))
+	if err != nil {
+		return err
+	}
+	defer galleryFile.Close()
+
+	return png.Encode(galleryFile, img)
+}
+
+const sampleMarkdown = `
+This is the first paragraph of sample content, which contains **bold**,
+*italic*, ` + "`code`" + ` and [a link](https://example.com).
+
+## Sample heading
+
+- First item
+- Second item
+
+` + "```go" + `
+func main() {
+	fmt.Println("Hello world")
+}
+` + "```" + `
+
+![Sample image](_thumbnail.png "Sample title")
+
+{{< gallery >}}
+
+| Column 1 | Column 2 |
+|----------|----------|
+| Cell 1   | Cell 2   |
+`
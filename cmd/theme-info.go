@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/theme"
+	"github.com/spf13/cobra"
+)
+
+func themeInfoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info [name]",
+		Short: "Show detail of a theme, or the active theme if name is not specified",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  themeInfoHandler,
+	}
+}
+
+func themeInfoHandler(cmd *cobra.Command, args []string) error {
+	// Make sure valid config file exists in current working dir
+	config, err := openConfigFile(false)
+	if err != nil {
+		return configError(err, "Failed to open config file")
+	}
+
+	// Get working dir
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return ioError(err, "Failed to get working dir")
+	}
+
+	// Read arguments
+	name := config.Theme
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	if name == "" {
+		return configError(nil, "No theme specified in config file")
+	}
+
+	// Open theme and its parents
+	themeChain, err := theme.Chain(os.DirFS(rootDir), name)
+	if err != nil {
+		return templateError(err, "Failed to open theme")
+	}
+
+	// Print theme detail
+	th := themeChain[0]
+	printField := func(label, value string) {
+		if value == "" {
+			value = "-"
+		}
+		cBold.Printf("%-16s: ", label)
+		fmt.Println(value)
+	}
+
+	printField("Name", th.Name)
+	printField("Description", th.Description)
+	printField("Author", th.Author)
+	printField("License", th.License)
+	printField("Min. version", th.MinVersion)
+	printField("Parent", th.Parent)
+	printField("Path", fp.Join(rootDir, fp.FromSlash(th.Path)))
+	printField("Templates", strings.Join(th.Templates, ", "))
+	printField("Missing", strings.Join(theme.MissingTemplates(themeChain), ", "))
+
+	// Print params after merged with its parents
+	params := theme.Params(themeChain, model.Config{})
+	keys := []string{}
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cBold.Println("Params")
+	for _, key := range keys {
+		fmt.Printf("  %s = %v\n", key, params[key])
+	}
+
+	// Print validation result
+	fmt.Println()
+	if err = theme.Validate(os.DirFS(rootDir), name); err != nil {
+		return templateError(err, "Theme is not valid")
+	}
+
+	fmt.Println("Theme is valid and can be used by this version of Spook")
+
+	return nil
+}
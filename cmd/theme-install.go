@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/theme"
+	"github.com/spf13/cobra"
+)
+
+func themeInstallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install [path]",
+		Short: "Install a theme from a zip archive, tar.gz archive or directory",
+		Args:  cobra.ExactArgs(1),
+		RunE:  themeInstallHandler,
+	}
+
+	cmd.Flags().String("name", "", "name of the installed theme")
+	cmd.Flags().Bool("force", false, "replace the existing theme with the same name")
+
+	return cmd
+}
+
+func themeInstallHandler(cmd *cobra.Command, args []string) error {
+	// Read arguments
+	srcPath, _ := fp.Abs(args[0])
+	name, _ := cmd.Flags().GetString("name")
+	isForced, _ := cmd.Flags().GetBool("force")
+
+	// Make sure valid config file exists in current working dir
+	_, err := openConfigFile(false)
+	if err != nil {
+		return configError(err, "Failed to open config file")
+	}
+
+	// Get working dir
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return ioError(err, "Failed to get working dir")
+	}
+
+	// Extract the theme into temporary staging directory, so an interrupted
+	// install doesn't leave a broken theme inside theme dir.
+	stagingDir, err := ioutil.TempDir("", "spook-install-")
+	if err != nil {
+		return ioError(err, "Failed to create staging dir")
+	}
+	defer os.RemoveAll(stagingDir)
+
+	lowerPath := strings.ToLower(srcPath)
+	defaultName := fp.Base(srcPath)
+
+	switch {
+	case dirExists(srcPath):
+		err = mergeDir(srcPath, stagingDir)
+	case strings.HasSuffix(lowerPath, ".zip"):
+		defaultName = defaultName[:len(defaultName)-len(".zip")]
+		err = extractZip(srcPath, stagingDir)
+	case strings.HasSuffix(lowerPath, ".tar.gz"):
+		defaultName = defaultName[:len(defaultName)-len(".tar.gz")]
+		err = extractTarGz(srcPath, stagingDir)
+	case strings.HasSuffix(lowerPath, ".tgz"):
+		defaultName = defaultName[:len(defaultName)-len(".tgz")]
+		err = extractTarGz(srcPath, stagingDir)
+	default:
+		err = fmt.Errorf("%s is not a directory, zip or tar.gz archive", srcPath)
+	}
+
+	if err != nil {
+		return ioError(err, "Failed to extract theme")
+	}
+
+	// Archives usually wrap the theme inside a single directory,
+	// so in that case use that directory as the theme root.
+	themeRoot := stagingDir
+	items, err := ioutil.ReadDir(themeRoot)
+	if err == nil && len(items) == 1 && items[0].IsDir() {
+		defaultName = items[0].Name()
+		themeRoot = fp.Join(themeRoot, items[0].Name())
+	}
+
+	// Open the theme. If its manifest doesn't specify the name, the name
+	// falls back to the name of directory, which is meaningless here.
+	th, err := theme.OpenDir(os.DirFS(fp.Dir(themeRoot)), fp.Base(themeRoot))
+	if err != nil {
+		return templateError(err, "Failed to open theme")
+	}
+
+	if name == "" && th.Name != fp.Base(themeRoot) {
+		name = themeDirName(th.Name)
+	}
+
+	if name == "" {
+		name = defaultName
+	}
+
+	if name == "" || name != fp.Base(name) || strings.HasPrefix(name, ".") {
+		return usageError(nil, "Theme name %q is not valid", name)
+	}
+
+	// Make sure theme is valid, including its parent which must be installed already
+	themeChain := []model.Theme{th}
+	if th.Parent != "" {
+		parentChain, err := theme.Chain(os.DirFS(rootDir), th.Parent)
+		if err != nil {
+			return templateError(err, "Failed to open parent theme")
+		}
+		themeChain = append(themeChain, parentChain...)
+	}
+
+	err = theme.ValidateChain(themeChain)
+	if err != nil {
+		return templateError(err, "Invalid theme")
+	}
+
+	// Move theme to its final location
+	themesDir := fp.Join(rootDir, "theme")
+	err = os.MkdirAll(themesDir, os.ModePerm)
+	if err != nil {
+		return ioError(err, "Failed to create theme dir")
+	}
+
+	themeDir := fp.Join(themesDir, name)
+	if _, err := os.Stat(themeDir); err == nil {
+		if !isForced {
+			return usageError(nil, "Theme %s already exists, use --force to replace it", name)
+		}
+
+		err = os.RemoveAll(themeDir)
+		if err != nil {
+			return ioError(err, "Failed to remove existing theme")
+		}
+	}
+
+	// Staging dir might be in another file system, in which
+	// case the theme can't be renamed, so copy it instead.
+	err = os.Rename(themeRoot, themeDir)
+	if err != nil {
+		err = copyDir(themeRoot, themeDir)
+	}
+
+	if err != nil {
+		os.RemoveAll(themeDir)
+		return ioError(err, "Failed to install theme")
+	}
+
+	// Finish
+	fmt.Print("Congratulations! Theme is installed in ")
+	cBold.Println(themeDir)
+
+	return nil
+}
+
+// themeDirName converts the theme name from manifest into name of theme dir, i.e. lowercase
+// letters and digits separated by dash, e.g. "My Theme 2.0" becomes "my-theme-2-0".
+func themeDirName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	return strings.Join(words, "-")
+}
+
+// safeJoin joins the name of archive entry to the dst dir, and makes sure
+// the result is still inside dst dir to prevent path traversal.
+func safeJoin(dst string, name string) (string, error) {
+	path := fp.Join(dst, fp.FromSlash(name))
+	if path != dst && !strings.HasPrefix(path, dst+string(fp.Separator)) {
+		return "", fmt.Errorf("entry %s is outside of target dir", name)
+	}
+
+	return path, nil
+}
+
+// writeFileFrom writes the content of reader r into a new file in path.
+func writeFileFrom(path string, r io.Reader) error {
+	err := os.MkdirAll(fp.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// extractZip extracts zip archive in src into dst directory.
+func extractZip(src, dst string) error {
+	archive, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		dstPath, err := safeJoin(dst, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := entry.Mode()
+		if mode.IsDir() {
+			err = os.MkdirAll(dstPath, os.ModePerm)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Skip symlinks and other special files
+		if !mode.IsRegular() {
+			continue
+		}
+
+		r, err := entry.Open()
+		if err != nil {
+			return err
+		}
+
+		err = writeFileFrom(dstPath, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractTarGz extracts gzipped tar archive in src into dst directory.
+func extractTarGz(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzReader, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		dstPath, err := safeJoin(dst, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dstPath, os.ModePerm)
+		case tar.TypeReg:
+			err = writeFileFrom(dstPath, tarReader)
+		default:
+			// Skip symlinks and other special files
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
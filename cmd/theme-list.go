@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/go-spook/spook/theme"
+	"github.com/spf13/cobra"
+)
+
+func themeListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List themes that installed in the site",
+		Args:  cobra.NoArgs,
+		RunE:  themeListHandler,
+	}
+}
+
+func themeListHandler(cmd *cobra.Command, args []string) error {
+	// Make sure valid config file exists in current working dir
+	config, err := openConfigFile(false)
+	if err != nil {
+		return configError(err, "Failed to open config file")
+	}
+
+	// Get working dir
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return ioError(err, "Failed to get working dir")
+	}
+
+	// Read all themes
+	themes, err := theme.List(os.DirFS(rootDir))
+	if err != nil {
+		return ioError(err, "Failed to read theme dir")
+	}
+
+	if len(themes) == 0 {
+		fmt.Println("There are no theme installed in this site")
+		return nil
+	}
+
+	// Print the themes, mark the active one
+	for _, th := range themes {
+		dirName := path.Base(th.Path)
+		if dirName == config.Theme {
+			cBold.Print("* ", dirName)
+		} else {
+			fmt.Print("  ", dirName)
+		}
+
+		if th.Description != "" {
+			fmt.Print(" - ", th.Description)
+		}
+
+		fmt.Println()
+	}
+
+	return nil
+}
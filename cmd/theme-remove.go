@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/go-spook/spook/theme"
+	"github.com/spf13/cobra"
+)
+
+func themeRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove [name]",
+		Short: "Remove a theme that not used by the site",
+		Args:  cobra.ExactArgs(1),
+		RunE:  themeRemoveHandler,
+	}
+}
+
+func themeRemoveHandler(cmd *cobra.Command, args []string) error {
+	// Read arguments
+	name := args[0]
+	if name == "" || name != fp.Base(name) || strings.HasPrefix(name, ".") {
+		return usageError(nil, "Theme name %q is not valid", name)
+	}
+
+	// Make sure valid config file exists in current working dir
+	config, err := openConfigFile(false)
+	if err != nil {
+		return configError(err, "Failed to open config file")
+	}
+
+	// Get working dir
+	rootDir, err := os.Getwd()
+	if err != nil {
+		return ioError(err, "Failed to get working dir")
+	}
+
+	// Make sure theme exists
+	themeDir := fp.Join(rootDir, "theme", name)
+	if !dirExists(themeDir) {
+		return usageError(nil, "Theme %s is not exist", name)
+	}
+
+	// Make sure theme is not used by the site, either directly or as parent
+	if config.Theme != "" {
+		if name == config.Theme {
+			return usageError(nil, "Theme %s is currently used by the site", name)
+		}
+
+		themeChain, err := theme.Chain(os.DirFS(rootDir), config.Theme)
+		if err == nil {
+			for _, th := range themeChain {
+				if path.Base(th.Path) == name {
+					return usageError(nil, "Theme %s is the parent of theme %s that used by the site", name, config.Theme)
+				}
+			}
+		}
+	}
+
+	// Remove the theme
+	err = os.RemoveAll(themeDir)
+	if err != nil {
+		return ioError(err, "Failed to remove theme")
+	}
+
+	fmt.Print("Theme is removed from ")
+	cBold.Println(themeDir)
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func themeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "theme",
+		Short: "Manage themes that installed in the site",
+	}
+
+	cmd.AddCommand(themeListCmd(), themeInfoCmd(), themeCheckCmd(),
+		themeInstallCmd(), themeRemoveCmd())
+	return cmd
+}
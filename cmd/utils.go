@@ -142,7 +142,16 @@ func copyDir(src, dst string, excludedFiles ...string) error {
 		return err
 	}
 
-	err = os.MkdirAll(dst, os.ModePerm)
+	return mergeDir(src, dst, excludedFiles...)
+}
+
+// mergeDir copies the content of src directory into dst directory.
+// Unlike copyDir, the existing files in dst which not exist in src are kept.
+func mergeDir(src, dst string, excludedFiles ...string) error {
+	src = fp.Clean(src)
+	dst = fp.Clean(dst)
+
+	err := os.MkdirAll(dst, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -158,7 +167,7 @@ func copyDir(src, dst string, excludedFiles ...string) error {
 		dstPath := fp.Join(dst, entry.Name())
 
 		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath, excludedFiles...)
+			err = mergeDir(srcPath, dstPath, excludedFiles...)
 			if err != nil {
 				return err
 			}
@@ -192,41 +201,6 @@ func copyDir(src, dst string, excludedFiles ...string) error {
 	return nil
 }
 
-func removeDirContents(dirPath string) error {
-	dirItems, err := ioutil.ReadDir(dirPath)
-	if err != nil {
-		return err
-	}
-
-	for _, dirItem := range dirItems {
-		// Skip hidden file or directory (like .git)
-		if strings.HasPrefix(dirItem.Name(), ".") {
-			continue
-		}
-
-		// Skip CNAME as well, since it's used for Github pages
-		if strings.ToLower(dirItem.Name()) == "cname" {
-			continue
-		}
-
-		dirItemPath := fp.Join(dirPath, dirItem.Name())
-		if dirItem.IsDir() {
-			err = removeDirContents(dirItemPath)
-			if err != nil {
-				return err
-			}
-			continue
-		}
-
-		err = os.Remove(dirItemPath)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 func checkError(err error) {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,325 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// EXIF tags that read from the image.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// EXIF is the metadata that embedded in JPEG image by camera.
+type EXIF struct {
+	Make      string
+	Model     string
+	Camera    string
+	DateTaken time.Time
+	HasGPS    bool
+	Latitude  float64
+	Longitude float64
+}
+
+// ReadEXIF reads EXIF metadata from JPEG image with the name in fsys.
+// Returns nil if the image doesn't have any EXIF metadata.
+func ReadEXIF(fsys fs.FS, name string) (*EXIF, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exif *EXIF
+	err = walkJPEGSegments(f, func(marker byte, data []byte) (bool, error) {
+		if marker != 0xE1 || !bytes.HasPrefix(data, exifHeader) {
+			return true, nil
+		}
+
+		exif, err = parseEXIF(data[len(exifHeader):])
+		return false, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exif, nil
+}
+
+// StripEXIF removes EXIF metadata from content of JPEG image. The image data is
+// left untouched, so the image doesn't lose its quality. If the image doesn't
+// contain EXIF metadata, its content is returned as it is.
+func StripEXIF(content []byte) ([]byte, error) {
+	if len(content) < 2 {
+		return content, nil
+	}
+
+	output := bytes.Buffer{}
+	output.Write(content[:2])
+
+	stripped := false
+	reader := bytes.NewReader(content)
+	err := walkJPEGSegments(reader, func(marker byte, data []byte) (bool, error) {
+		if marker == 0xE1 && bytes.HasPrefix(data, exifHeader) {
+			stripped = true
+			return true, nil
+		}
+
+		length := make([]byte, 2)
+		binary.BigEndian.PutUint16(length, uint16(len(data)+2))
+		output.Write([]byte{0xFF, marker})
+		output.Write(length)
+		output.Write(data)
+
+		// Once the scan started, the rest is image data
+		if marker == 0xDA {
+			output.Write(content[len(content)-reader.Len():])
+			return false, nil
+		}
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !stripped {
+		return content, nil
+	}
+
+	return output.Bytes(), nil
+}
+
+// walkJPEGSegments calls fn for each metadata segment in JPEG image, until the start of
+// scan segment is found or fn returns false. The segment data excludes the marker and
+// length bytes.
+func walkJPEGSegments(r io.Reader, fn func(marker byte, data []byte) (bool, error)) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+
+	if header[0] != 0xFF || header[1] != 0xD8 {
+		return fmt.Errorf("not a JPEG image")
+	}
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+
+		if header[0] != 0xFF {
+			return fmt.Errorf("invalid JPEG segment marker")
+		}
+
+		// Skip the padding
+		marker := header[1]
+		if marker == 0xFF {
+			continue
+		}
+
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return err
+		}
+
+		size := int(binary.BigEndian.Uint16(length)) - 2
+		if size < 0 {
+			return fmt.Errorf("invalid JPEG segment length")
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+
+		next, err := fn(marker, data)
+		if err != nil || !next || marker == 0xDA {
+			return err
+		}
+	}
+}
+
+// tiffReader reads the IFD entries in TIFF structure that used by EXIF.
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is an entry in image file directory.
+type ifdEntry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Value []byte
+}
+
+// parseEXIF parses the TIFF structure inside EXIF segment.
+func parseEXIF(data []byte) (*EXIF, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("EXIF data is too short")
+	}
+
+	tr := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		tr.order = binary.LittleEndian
+	case "MM":
+		tr.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid EXIF byte order")
+	}
+
+	entries, err := tr.readIFD(tr.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &EXIF{}
+	exif.Make = tr.asciiValue(entries[tagMake])
+	exif.Model = tr.asciiValue(entries[tagModel])
+
+	// Some cameras already put the brand name in the model name
+	exif.Camera = exif.Model
+	if exif.Make != "" && !strings.HasPrefix(strings.ToLower(exif.Model), strings.ToLower(exif.Make)) {
+		exif.Camera = strings.TrimSpace(exif.Make + " " + exif.Model)
+	}
+
+	if entry, exist := entries[tagExifIFD]; exist {
+		exifEntries, err := tr.readIFD(tr.order.Uint32(entry.Value))
+		if err == nil {
+			// The time doesn't have time zone, so it's parsed as UTC to make
+			// it the same regardless of the time zone of the build machine.
+			strTime := tr.asciiValue(exifEntries[tagDateTimeOriginal])
+			exif.DateTaken, _ = time.Parse("2006:01:02 15:04:05", strTime)
+		}
+	}
+
+	if entry, exist := entries[tagGPSIFD]; exist {
+		gpsEntries, err := tr.readIFD(tr.order.Uint32(entry.Value))
+		if err == nil {
+			lat, okLat := tr.coordinateValue(gpsEntries[tagGPSLatitude], tr.asciiValue(gpsEntries[tagGPSLatitudeRef]))
+			long, okLong := tr.coordinateValue(gpsEntries[tagGPSLongitude], tr.asciiValue(gpsEntries[tagGPSLongitudeRef]))
+			if okLat && okLong {
+				exif.HasGPS = true
+				exif.Latitude = lat
+				exif.Longitude = long
+			}
+		}
+	}
+
+	return exif, nil
+}
+
+// readIFD reads entries in image file directory at the specified offset.
+func (tr tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	if int(offset)+2 > len(tr.data) {
+		return nil, fmt.Errorf("invalid IFD offset")
+	}
+
+	nEntries := int(tr.order.Uint16(tr.data[offset:]))
+	entries := map[uint16]ifdEntry{}
+
+	for i := 0; i < nEntries; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tr.data) {
+			return nil, fmt.Errorf("IFD entry is out of range")
+		}
+
+		raw := tr.data[start : start+12]
+		entry := ifdEntry{
+			Tag:   tr.order.Uint16(raw[0:]),
+			Type:  tr.order.Uint16(raw[2:]),
+			Count: tr.order.Uint32(raw[4:]),
+		}
+
+		// The value is stored in the entry itself if it fits in 4 bytes,
+		// otherwise the entry contains the offset of value.
+		size := typeSize(entry.Type) * int(entry.Count)
+		if size <= 4 {
+			entry.Value = raw[8:12]
+		} else {
+			valueOffset := int(tr.order.Uint32(raw[8:]))
+			if valueOffset+size > len(tr.data) {
+				continue
+			}
+			entry.Value = tr.data[valueOffset : valueOffset+size]
+		}
+
+		entries[entry.Tag] = entry
+	}
+
+	return entries, nil
+}
+
+// asciiValue returns the value of entry with ASCII type.
+func (tr tiffReader) asciiValue(entry ifdEntry) string {
+	if entry.Type != 2 {
+		return ""
+	}
+
+	value := entry.Value
+	if int(entry.Count) < len(value) {
+		value = value[:entry.Count]
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+}
+
+// coordinateValue returns the GPS coordinate in decimal degrees, from an entry
+// that contains degrees, minutes and seconds as rationals.
+func (tr tiffReader) coordinateValue(entry ifdEntry, ref string) (float64, bool) {
+	if entry.Type != 5 || entry.Count != 3 {
+		return 0, false
+	}
+
+	values := [3]float64{}
+	for i := range values {
+		numerator := tr.order.Uint32(entry.Value[i*8:])
+		denominator := tr.order.Uint32(entry.Value[i*8+4:])
+		if denominator == 0 {
+			return 0, false
+		}
+		values[i] = float64(numerator) / float64(denominator)
+	}
+
+	coordinate := values[0] + values[1]/60 + values[2]/3600
+	if ref == "S" || ref == "W" {
+		coordinate = -coordinate
+	}
+
+	return coordinate, true
+}
+
+// typeSize returns the size in bytes of a value with the specified TIFF type.
+func typeSize(dataType uint16) int {
+	switch dataType {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 11:
+		return 4
+	case 5, 10, 12:
+		return 8
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,452 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	fp "path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/output"
+)
+
+// DefaultQuality is the JPEG quality that used if it's not specified in config.
+const DefaultQuality = 85
+
+var rxVariantName = regexp.MustCompile(`^(.+)_(\d+)w(\.[^.]+)$`)
+
+// Processor generates resized variants of images inside the site directory. The images
+// are read from FS, which is the file system of the site root dir, and referred by their
+// URL, which is the same as their path relative to root dir, e.g. /post/my-post/photo.jpg.
+// The generated variants are saved in cache dir, keyed by hash of the original content,
+// so they can be reused in the next build. Processor is safe for concurrent use.
+type Processor struct {
+	Config   model.ImageConfig
+	FS       fs.FS
+	CacheDir string
+
+	mutex    sync.Mutex
+	hashes   map[string]fileHash
+	infos    map[string]Info
+	variants map[string]string
+}
+
+// Info is the dimension and EXIF metadata of an image.
+// EXIF is nil if the image doesn't have any EXIF metadata.
+type Info struct {
+	Width  int
+	Height int
+	EXIF   *EXIF
+}
+
+// Variant is a resized variant of an image.
+type Variant struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// fileHash is the content hash of a file, which is valid
+// as long as the size and modification time are unchanged.
+type fileHash struct {
+	Size    int64
+	ModTime int64
+	Hash    string
+}
+
+// New returns a new image processor for site in fsys,
+// which saves the generated variants into cache dir.
+func New(fsys fs.FS, cacheDir string, config model.ImageConfig) *Processor {
+	if config.Quality <= 0 || config.Quality > 100 {
+		config.Quality = DefaultQuality
+	}
+
+	return &Processor{
+		Config:   config,
+		FS:       fsys,
+		CacheDir: cacheDir,
+		hashes:   map[string]fileHash{},
+		infos:    map[string]Info{},
+		variants: map[string]string{},
+	}
+}
+
+// IsSupported checks whether the image in URL can be resized.
+func IsSupported(imageURL string) bool {
+	switch strings.ToLower(path.Ext(imageURL)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	default:
+		return false
+	}
+}
+
+// VariantURL returns the URL of image variant with the specified width,
+// e.g. /post/my-post/photo_480w.jpg for /post/my-post/photo.jpg.
+func VariantURL(imageURL string, width int) string {
+	ext := path.Ext(imageURL)
+	return fmt.Sprintf("%s_%dw%s", strings.TrimSuffix(imageURL, ext), width, ext)
+}
+
+// ParseVariantURL returns the URL of original image and the width of variant.
+// Returns false if the URL is not URL of image variant.
+func ParseVariantURL(variantURL string) (string, int, bool) {
+	parts := rxVariantName.FindStringSubmatch(variantURL)
+	if parts == nil {
+		return "", 0, false
+	}
+
+	width, err := strconv.Atoi(parts[2])
+	if err != nil || width <= 0 {
+		return "", 0, false
+	}
+
+	return parts[1] + parts[3], width, true
+}
+
+// Size returns the width and height of image in URL.
+func (p *Processor) Size(imageURL string) (int, int, error) {
+	info, err := p.Info(imageURL)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return info.Width, info.Height, nil
+}
+
+// Info returns the dimension and EXIF metadata of image in URL. Since decoding them is
+// expensive, the result is cached by hash of the image content. The returned EXIF is
+// shared between callers, so it must not be modified. The invalid EXIF metadata is
+// ignored, since the image itself is still usable.
+func (p *Processor) Info(imageURL string) (Info, error) {
+	hash, err := p.contentHash(imageURL)
+	if err != nil {
+		return Info{}, err
+	}
+
+	p.mutex.Lock()
+	info, exist := p.infos[hash]
+	p.mutex.Unlock()
+
+	if exist {
+		return info, nil
+	}
+
+	localPath := p.localPath(imageURL)
+	info.Width, info.Height, err = Size(p.FS, localPath)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if ext := strings.ToLower(path.Ext(localPath)); ext == ".jpg" || ext == ".jpeg" {
+		info.EXIF, _ = ReadEXIF(p.FS, localPath)
+	}
+
+	p.mutex.Lock()
+	p.infos[hash] = info
+	p.mutex.Unlock()
+
+	return info, nil
+}
+
+// Size returns the width and height of JPEG or PNG image with the name in fsys.
+func Size(fsys fs.FS, name string) (int, int, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode %s: %v", path.Base(name), err)
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// Resize generates variant of image in URL with the specified width, keeping its aspect
+// ratio. If the width is not smaller than the original, the original image is returned.
+func (p *Processor) Resize(imageURL string, width int) (Variant, error) {
+	if !IsSupported(imageURL) {
+		return Variant{}, fmt.Errorf("%s is not JPEG or PNG image", imageURL)
+	}
+
+	origWidth, origHeight, err := p.Size(imageURL)
+	if err != nil {
+		return Variant{}, err
+	}
+
+	if width <= 0 || width >= origWidth {
+		return Variant{URL: imageURL, Width: origWidth, Height: origHeight}, nil
+	}
+
+	variant := Variant{
+		URL:    VariantURL(imageURL, width),
+		Width:  width,
+		Height: (origHeight*width + origWidth/2) / origWidth,
+	}
+
+	if variant.Height < 1 {
+		variant.Height = 1
+	}
+
+	// Check if the variant already exists in cache
+	hash, err := p.contentHash(imageURL)
+	if err != nil {
+		return Variant{}, err
+	}
+
+	ext := strings.ToLower(path.Ext(imageURL))
+	cacheName := fmt.Sprintf("%s_%dw_q%d%s", hash, width, p.Config.Quality, ext)
+	cachePath := fp.Join(p.CacheDir, cacheName)
+
+	if _, err := os.Stat(cachePath); err != nil {
+		err = p.generate(imageURL, cachePath, variant.Width, variant.Height)
+		if err != nil {
+			return Variant{}, err
+		}
+	}
+
+	p.mutex.Lock()
+	p.variants[variant.URL] = cachePath
+	p.mutex.Unlock()
+
+	return variant, nil
+}
+
+// Srcset generates variants of image in URL for each width, then returns the value
+// for srcset attribute along with the size of the original image.
+func (p *Processor) Srcset(imageURL string, widths []int) (string, int, int, error) {
+	origWidth, origHeight, err := p.Size(imageURL)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	sortedWidths := append([]int{}, widths...)
+	sort.Ints(sortedWidths)
+
+	candidates := []string{}
+	for _, width := range sortedWidths {
+		if width <= 0 || width >= origWidth {
+			continue
+		}
+
+		variant, err := p.Resize(imageURL, width)
+		if err != nil {
+			return "", 0, 0, err
+		}
+
+		candidates = append(candidates, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+	}
+
+	candidates = append(candidates, fmt.Sprintf("%s %dw", imageURL, origWidth))
+	return strings.Join(candidates, ", "), origWidth, origHeight, nil
+}
+
+// CachedFile returns path to the cached file of image variant in URL, generating it
+// if needed. Useful for serving the variants without writing them to output dir.
+func (p *Processor) CachedFile(variantURL string) (string, error) {
+	p.mutex.Lock()
+	cachePath, exist := p.variants[variantURL]
+	p.mutex.Unlock()
+
+	if exist {
+		return cachePath, nil
+	}
+
+	imageURL, width, ok := ParseVariantURL(variantURL)
+	if !ok {
+		return "", fmt.Errorf("%s is not an image variant", variantURL)
+	}
+
+	variant, err := p.Resize(imageURL, width)
+	if err != nil {
+		return "", err
+	}
+
+	if variant.URL != variantURL {
+		return "", fmt.Errorf("%s is not an image variant", variantURL)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.variants[variantURL], nil
+}
+
+// Variants returns the URL of all variants that generated by this processor.
+func (p *Processor) Variants() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	urls := []string{}
+	for variantURL := range p.variants {
+		urls = append(urls, variantURL)
+	}
+	sort.Strings(urls)
+
+	return urls
+}
+
+// VariantFiles returns path to the cached file of all variants that generated by
+// this processor, keyed by their URL.
+func (p *Processor) VariantFiles() map[string]string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	files := map[string]string{}
+	for variantURL, cachePath := range p.variants {
+		files[variantURL] = cachePath
+	}
+
+	return files
+}
+
+// WriteVariants copies all variants that generated by this processor into output sink,
+// using modTime as their modification time. The variants in written, which maps their
+// URL to the cached file that already copied into the sink, are skipped as long as
+// their cached file is unchanged. Since the name of cached file contains the hash of
+// original image, the same cached file means the variant is unchanged.
+func (p *Processor) WriteVariants(sink output.Sink, modTime time.Time, written map[string]string) error {
+	files := p.VariantFiles()
+	for _, variantURL := range p.Variants() {
+		cachePath := files[variantURL]
+		if written[variantURL] == cachePath {
+			continue
+		}
+
+		err := copyFile(cachePath, sink, strings.TrimPrefix(variantURL, "/"), modTime)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %v", variantURL, err)
+		}
+	}
+
+	return nil
+}
+
+// localPath returns name of file in URL inside the file system of root dir.
+func (p *Processor) localPath(fileURL string) string {
+	return strings.TrimPrefix(path.Clean("/"+fileURL), "/")
+}
+
+// contentHash returns the hash of content of file in URL. The hash is
+// memoized as long as the size and modification time are unchanged.
+func (p *Processor) contentHash(fileURL string) (string, error) {
+	localPath := p.localPath(fileURL)
+	info, err := fs.Stat(p.FS, localPath)
+	if err != nil {
+		return "", err
+	}
+
+	p.mutex.Lock()
+	cached, exist := p.hashes[localPath]
+	p.mutex.Unlock()
+
+	if exist && cached.Size == info.Size() && cached.ModTime == info.ModTime().UnixNano() {
+		return cached.Hash, nil
+	}
+
+	f, err := p.FS.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))[:32]
+
+	p.mutex.Lock()
+	p.hashes[localPath] = fileHash{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Hash:    hash,
+	}
+	p.mutex.Unlock()
+
+	return hash, nil
+}
+
+// generate decodes image in URL, resizes it, then saves it into cache path.
+func (p *Processor) generate(imageURL string, cachePath string, width, height int) error {
+	f, err := p.FS.Open(p.localPath(imageURL))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	src, format, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", imageURL, err)
+	}
+
+	dst := resize(src, width, height)
+
+	// Write into temporary file first, so the incomplete
+	// file never used as cache when something goes wrong.
+	err = os.MkdirAll(p.CacheDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(p.CacheDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	switch format {
+	case "png":
+		err = png.Encode(tmpFile, dst)
+	default:
+		err = jpeg.Encode(tmpFile, dst, &jpeg.Options{Quality: p.Config.Quality})
+	}
+
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", imageURL, err)
+	}
+
+	return os.Rename(tmpFile.Name(), cachePath)
+}
+
+// copyFile copies file in src path into the file with the name in output sink.
+func copyFile(srcPath string, sink output.Sink, name string, modTime time.Time) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := sink.Create(name, modTime)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+
+	return err
+}
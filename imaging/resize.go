@@ -0,0 +1,100 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// resize scales down the image into the specified size using area averaging,
+// i.e. each pixel in the result is the weighted average of the source pixels
+// that covered by it. It gives a smooth result for downscaling, which is the
+// only scaling that done by the processor.
+func resize(src image.Image, width, height int) *image.RGBA {
+	// Convert the source into RGBA, so its pixels can be accessed directly
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	xWeights := areaWeights(srcWidth, width)
+	yWeights := areaWeights(srcHeight, height)
+
+	// Scale horizontally first into a temporary buffer, then vertically into the result.
+	// The buffer keeps the sum of weighted colors, scaled by srcWidth to stay integer.
+	tmp := make([]uint64, width*srcHeight*4)
+	for y := 0; y < srcHeight; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, weights := range xWeights {
+			offset := (y*width + x) * 4
+			for _, w := range weights {
+				px := row[w.Index*4:]
+				for c := 0; c < 4; c++ {
+					tmp[offset+c] += uint64(px[c]) * w.Weight
+				}
+			}
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	divisor := uint64(srcWidth) * uint64(srcHeight)
+	for y, weights := range yWeights {
+		for x := 0; x < width; x++ {
+			sum := [4]uint64{}
+			for _, w := range weights {
+				offset := (w.Index*width + x) * 4
+				for c := 0; c < 4; c++ {
+					sum[c] += tmp[offset+c] * w.Weight
+				}
+			}
+
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8((sum[c] + divisor/2) / divisor)
+			}
+		}
+	}
+
+	return dst
+}
+
+// weight is the contribution of a source pixel to a destination pixel.
+type weight struct {
+	Index  int
+	Weight uint64
+}
+
+// areaWeights calculates the weights of source pixels for each destination pixel, when
+// scaling srcSize pixels into dstSize pixels. To keep the calculation in integer, both
+// sizes are scaled by the other size, so each destination pixel covers exactly srcSize
+// units and each source pixel covers exactly dstSize units. The weights of each
+// destination pixel are summed up to srcSize.
+func areaWeights(srcSize, dstSize int) [][]weight {
+	result := make([][]weight, dstSize)
+	for i := range result {
+		start := i * srcSize
+		end := start + srcSize
+
+		for j := start / dstSize; j*dstSize < end && j < srcSize; j++ {
+			pxStart, pxEnd := j*dstSize, (j+1)*dstSize
+			if pxStart < start {
+				pxStart = start
+			}
+
+			if pxEnd > end {
+				pxEnd = end
+			}
+
+			if pxEnd > pxStart {
+				result[i] = append(result[i], weight{
+					Index:  j,
+					Weight: uint64(pxEnd - pxStart),
+				})
+			}
+		}
+	}
+
+	return result
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
+	"os"
+
 	"github.com/go-spook/spook/cmd"
-	"github.com/sirupsen/logrus"
 )
 
 func main() {
-	if err := cmd.SpookCmd().Execute(); err != nil {
-		logrus.Fatalln(err)
-	}
+	os.Exit(cmd.Execute())
 }
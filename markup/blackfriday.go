@@ -0,0 +1,186 @@
+package markup
+
+import (
+	"bytes"
+	"html"
+	"io"
+
+	bf "gopkg.in/russross/blackfriday.v2"
+)
+
+const bfExtensions = bf.CommonExtensions | bf.Footnotes | bf.HeadingIDs
+
+// blackfridayEngine is markdown engine that uses blackfriday.
+type blackfridayEngine struct{}
+
+// Convert converts markdown content into HTML.
+func (blackfridayEngine) Convert(src []byte, opts Options) ([]byte, error) {
+	r := &bfRenderer{
+		opts:        opts,
+		admonitions: map[*bf.Node]bool{},
+		checkboxes:  map[*bf.Node]string{},
+		HTMLRenderer: bf.NewHTMLRenderer(bf.HTMLRendererParameters{
+			Flags: bf.CommonHTMLFlags,
+		}),
+	}
+
+	output := bf.Run(src, bf.WithExtensions(bfExtensions), bf.WithRenderer(r))
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return output, nil
+}
+
+// bfRenderer is markdown renderer that extends the default HTML renderer
+// from blackfriday, so the information that dropped by it can be preserved
+// and the rendering of links and images can be customized by hooks.
+type bfRenderer struct {
+	*bf.HTMLRenderer
+	opts Options
+	err  error
+
+	admonitions map[*bf.Node]bool
+	checkboxes  map[*bf.Node]string
+}
+
+// RenderNode renders a single node of markdown.
+func (r *bfRenderer) RenderNode(w io.Writer, node *bf.Node, entering bool) bf.WalkStatus {
+	switch {
+	case node.Type == bf.CodeBlock:
+		writeCodeBlock(w, string(node.Info), string(node.Literal))
+		return bf.GoToNext
+	case node.Type == bf.Link && r.opts.LinkHook != nil && node.NoteID == 0 && entering:
+		r.renderLink(w, node)
+		return bf.SkipChildren
+	case node.Type == bf.Image && r.opts.ImageHook != nil && entering:
+		r.renderImage(w, node)
+		return bf.SkipChildren
+	case node.Type == bf.BlockQuote && entering:
+		if r.renderAdmonition(w, node) {
+			return bf.GoToNext
+		}
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	case node.Type == bf.BlockQuote && r.admonitions[node]:
+		io.WriteString(w, "</div>\n")
+		return bf.GoToNext
+	case node.Type == bf.Item && entering:
+		return r.renderItem(w, node)
+	case node.Type == bf.Text && r.checkboxes[node] != "":
+		io.WriteString(w, r.checkboxes[node])
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	default:
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+}
+
+// renderLink renders link using the link hook.
+func (r *bfRenderer) renderLink(w io.Writer, node *bf.Node) {
+	text := bytes.Buffer{}
+	for child := node.FirstChild; child != nil; child = child.Next {
+		child.Walk(func(n *bf.Node, entering bool) bf.WalkStatus {
+			return r.RenderNode(&text, n, entering)
+		})
+	}
+
+	link := Link{
+		Destination: string(node.LinkData.Destination),
+		Title:       string(node.LinkData.Title),
+		Text:        text.String(),
+		PlainText:   bfNodeText(node),
+	}
+
+	// Since the error can't be returned while walking the markdown nodes,
+	// the first error is saved and checked once the rendering finished.
+	if r.err == nil {
+		r.err = r.opts.LinkHook(w, link)
+	}
+}
+
+// renderImage renders image using the image hook.
+func (r *bfRenderer) renderImage(w io.Writer, node *bf.Node) {
+	image := Image{
+		Destination: string(node.LinkData.Destination),
+		Title:       string(node.LinkData.Title),
+		Alt:         bfNodeText(node),
+	}
+
+	if r.err == nil {
+		r.err = r.opts.ImageHook(w, image)
+	}
+}
+
+// renderAdmonition renders blockquote that started with GitHub-style marker, e.g. [!NOTE],
+// as admonition. The text after marker in the same line is used as the title. Returns
+// false if the blockquote is not an admonition, so it can be rendered as usual.
+func (r *bfRenderer) renderAdmonition(w io.Writer, node *bf.Node) bool {
+	paragraph := node.FirstChild
+	if paragraph == nil || paragraph.Type != bf.Paragraph {
+		return false
+	}
+
+	text := paragraph.FirstChild
+	if text == nil || text.Type != bf.Text {
+		return false
+	}
+
+	kind, title, rest, ok := parseAdmonitionMarker(string(text.Literal))
+	if !ok {
+		return false
+	}
+
+	// Remove the marker from content. If the paragraph only contains the
+	// marker, remove the paragraph entirely so no empty <p> is rendered.
+	text.Literal = []byte(rest)
+	if rest == "" && text.Next == nil {
+		paragraph.Unlink()
+	}
+
+	r.admonitions[node] = true
+	io.WriteString(w, `<div class="admonition `+kind+`">`+"\n")
+	io.WriteString(w, `<p class="admonition-title">`+html.EscapeString(title)+"</p>\n")
+	return true
+}
+
+// renderItem renders list item. If the item started with [ ] or [x], it's rendered
+// as task list item, with the marker replaced by disabled checkbox.
+func (r *bfRenderer) renderItem(w io.Writer, node *bf.Node) bf.WalkStatus {
+	var text *bf.Node
+	if paragraph := node.FirstChild; paragraph != nil && paragraph.Type == bf.Paragraph {
+		text = paragraph.FirstChild
+	}
+
+	if text == nil || text.Type != bf.Text {
+		return r.HTMLRenderer.RenderNode(w, node, true)
+	}
+
+	checked, rest, ok := parseTaskMarker(string(text.Literal))
+	if !ok {
+		return r.HTMLRenderer.RenderNode(w, node, true)
+	}
+
+	text.Literal = []byte(rest)
+	r.checkboxes[text] = `<input type="checkbox" disabled> `
+	if checked {
+		r.checkboxes[text] = `<input type="checkbox" checked disabled> `
+	}
+
+	// Let the default renderer writes the opening tag, then add the class into it
+	buffer := bytes.Buffer{}
+	status := r.HTMLRenderer.RenderNode(&buffer, node, true)
+	w.Write(bytes.Replace(buffer.Bytes(), []byte("<li>"), []byte(`<li class="task-list-item">`), 1))
+	return status
+}
+
+// bfNodeText returns the plain text inside the node.
+func bfNodeText(node *bf.Node) string {
+	text := bytes.Buffer{}
+	node.Walk(func(n *bf.Node, entering bool) bf.WalkStatus {
+		if entering && len(n.Literal) > 0 {
+			text.Write(n.Literal)
+		}
+		return bf.GoToNext
+	})
+
+	return text.String()
+}
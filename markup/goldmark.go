@@ -0,0 +1,133 @@
+package markup
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// goldmarkEngine is markdown engine that uses goldmark.
+type goldmarkEngine struct {
+	typographer bool
+}
+
+// Convert converts markdown content into HTML.
+func (e goldmarkEngine) Convert(src []byte, opts Options) ([]byte, error) {
+	extensions := []goldmark.Extender{
+		extension.GFM,
+		extension.DefinitionList,
+		extension.Footnote,
+	}
+
+	if e.typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+
+	nodeRenderer := &gmRenderer{opts: opts}
+	md := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parser.WithAttribute()),
+		goldmark.WithRendererOptions(
+			html.WithUnsafe(),
+			html.WithXHTML(),
+			renderer.WithNodeRenderers(util.Prioritized(nodeRenderer, 100)),
+		),
+	)
+	nodeRenderer.renderer = md.Renderer()
+
+	output := bytes.Buffer{}
+	err := md.Convert(src, &output)
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// gmRenderer is node renderer for goldmark, which overrides the default renderer for
+// fenced code block, so the information in its info string can be preserved. If the
+// hooks are specified, it also overrides the rendering of links and images.
+type gmRenderer struct {
+	opts     Options
+	renderer renderer.Renderer
+}
+
+// RegisterFuncs registers the functions for rendering nodes.
+func (r *gmRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+
+	if r.opts.LinkHook != nil {
+		reg.Register(ast.KindLink, r.renderLink)
+	}
+
+	if r.opts.ImageHook != nil {
+		reg.Register(ast.KindImage, r.renderImage)
+	}
+}
+
+func (r *gmRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	info := ""
+	if n.Info != nil {
+		info = string(n.Info.Segment.Value(source))
+	}
+
+	code := bytes.Buffer{}
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	writeCodeBlock(w, info, code.String())
+	return ast.WalkContinue, nil
+}
+
+func (r *gmRenderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.Link)
+	text := bytes.Buffer{}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		err := r.renderer.Render(&text, source, child)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+	}
+
+	link := Link{
+		Destination: string(n.Destination),
+		Title:       string(n.Title),
+		Text:        text.String(),
+		PlainText:   string(n.Text(source)),
+	}
+
+	return ast.WalkSkipChildren, r.opts.LinkHook(w, link)
+}
+
+func (r *gmRenderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.Image)
+	image := Image{
+		Destination: string(n.Destination),
+		Title:       string(n.Title),
+		Alt:         string(n.Text(source)),
+	}
+
+	return ast.WalkSkipChildren, r.opts.ImageHook(w, image)
+}
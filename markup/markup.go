@@ -0,0 +1,96 @@
+package markup
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-spook/spook/model"
+)
+
+const (
+	// EngineBlackfriday is the name of markdown engine that uses blackfriday.
+	// This is the default engine, for compatibility with the old sites.
+	EngineBlackfriday = "blackfriday"
+	// EngineGoldmark is the name of markdown engine that uses goldmark,
+	// which is compliant with CommonMark specification.
+	EngineGoldmark = "goldmark"
+)
+
+// Engine converts markdown content into HTML.
+type Engine interface {
+	Convert(src []byte, opts Options) ([]byte, error)
+}
+
+// Options is the options that used while converting markdown content.
+// If the hook is nil, the link or image is rendered by the engine itself.
+type Options struct {
+	LinkHook         func(w io.Writer, link Link) error
+	ImageHook        func(w io.Writer, image Image) error
+	WikiLinkResolver WikiLinkResolver
+	Shortcodes       map[string]Shortcode
+}
+
+// WikiLinkResolver returns the URL and title of the target of wiki link,
+// e.g. [[post-slug]]. Returns error if the target is not exist.
+type WikiLinkResolver func(target string) (url string, title string, err error)
+
+// Link is a link inside markdown content.
+type Link struct {
+	Destination string
+	Title       string
+	Text        string
+	PlainText   string
+}
+
+// Image is an image inside markdown content.
+type Image struct {
+	Destination string
+	Title       string
+	Alt         string
+}
+
+// Result is the result of converting markdown content.
+type Result struct {
+	HTML    []byte
+	HasMath bool
+}
+
+// Convert converts markdown content into HTML using the specified engine. Before converted,
+// the math expressions are protected so they're not mangled by the engine, then put back
+// into the HTML wrapped in element with class "math", ready for client side rendering.
+// The shortcodes are expanded first, then the wiki links are converted into the usual
+// links using the resolver in options.
+func Convert(engine Engine, src []byte, opts Options) (Result, error) {
+	src, err := expandShortcodes(src, opts.Shortcodes)
+	if err != nil {
+		return Result{}, err
+	}
+
+	src, exprs := protectMath(src)
+	src, err = replaceWikiLinks(src, opts.WikiLinkResolver)
+	if err != nil {
+		return Result{}, err
+	}
+
+	output, err := engine.Convert(src, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		HTML:    restoreMath(output, exprs),
+		HasMath: len(exprs) > 0,
+	}, nil
+}
+
+// New returns markdown engine that specified in config.
+func New(config model.MarkupConfig) (Engine, error) {
+	switch config.Engine {
+	case "", EngineBlackfriday:
+		return blackfridayEngine{}, nil
+	case EngineGoldmark:
+		return goldmarkEngine{typographer: config.Typographer}, nil
+	default:
+		return nil, fmt.Errorf("unknown markdown engine: %s", config.Engine)
+	}
+}
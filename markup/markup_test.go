@@ -0,0 +1,96 @@
+package markup
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	fp "path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-spook/spook/model"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestEngines converts every markdown file in testdata using each engine, then
+// compares the result with the golden file of that engine, e.g. the golden file of
+// tables.md for goldmark engine is tables.goldmark.html.
+func TestEngines(t *testing.T) {
+	inputs, err := fp.Glob(fp.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inputs) == 0 {
+		t.Fatal("no markdown files in testdata")
+	}
+
+	engines := []string{EngineBlackfriday, EngineGoldmark}
+	for _, engineName := range engines {
+		engine, err := New(model.MarkupConfig{Engine: engineName})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, input := range inputs {
+			name := strings.TrimSuffix(fp.Base(input), ".md")
+			t.Run(engineName+"/"+name, func(t *testing.T) {
+				golden := strings.TrimSuffix(input, ".md") + "." + engineName + ".html"
+				checkGolden(t, engine, input, golden)
+			})
+		}
+	}
+}
+
+// TestDefaultEngine makes sure the blackfriday engine is used when the engine
+// is not specified in config, so its output matches the blackfriday golden files.
+func TestDefaultEngine(t *testing.T) {
+	engine, err := New(model.MarkupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := engine.(blackfridayEngine); !ok {
+		t.Fatalf("default engine is %T, want blackfridayEngine", engine)
+	}
+
+	input := fp.Join("testdata", "tables.md")
+	golden := fp.Join("testdata", "tables."+EngineBlackfriday+".html")
+	checkGolden(t, engine, input, golden)
+}
+
+// checkGolden converts the markdown file in input using engine, then compares
+// the result with the golden file. If -update flag is used, the golden file is
+// replaced by the result instead.
+func checkGolden(t *testing.T, engine Engine, input string, golden string) {
+	t.Helper()
+
+	src, err := ioutil.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Convert(engine, src, Options{})
+	if err != nil {
+		t.Fatalf("failed to convert %s: %v", input, err)
+	}
+
+	if *update {
+		err = ioutil.WriteFile(golden, result.HTML, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(result.HTML, expected) {
+		t.Errorf("output of %s doesn't match %s\n--- got:\n%s\n--- want:\n%s",
+			input, golden, result.HTML, expected)
+	}
+}
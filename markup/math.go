@@ -0,0 +1,312 @@
+package markup
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var rxMathPlaceholder = regexp.MustCompile(`(?:<p>)?SPOOKMATH(\d+)X(?:</p>)?`)
+
+// mathExpr is a math expression that found in markdown content.
+type mathExpr struct {
+	Content string
+	Display bool
+}
+
+// protectMath finds the math expressions in markdown content and replaces them with
+// placeholders, so they're not mangled by markdown engine. The recognized delimiters
+// are $...$ and \(...\) for inline math, and $$...$$ and \[...\] for display math.
+// The math inside code block and code span is left untouched.
+func protectMath(src []byte) ([]byte, []mathExpr) {
+	exprs := []mathExpr{}
+	output := transformText(src, func(text []byte) []byte {
+		return protectInlineMath(text, &exprs)
+	})
+
+	return output, exprs
+}
+
+// transformText transforms the text in markdown content using function fn.
+// The code blocks are left untouched, so fn only receives the text outside them.
+func transformText(src []byte, fn func(text []byte) []byte) []byte {
+	output := bytes.Buffer{}
+	text := bytes.Buffer{}
+
+	flushText := func() {
+		output.Write(fn(text.Bytes()))
+		text.Reset()
+	}
+
+	fence := ""
+	prevBlank := true
+	inIndentedCode := false
+	inList := false
+
+	for _, line := range splitLines(src) {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		isBlank := strings.TrimSpace(line) == ""
+
+		// Inside fenced code block, wait until the closing fence
+		if fence != "" {
+			output.WriteString(line)
+			closing := strings.TrimSpace(trimmed)
+			if indent < 4 && strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+
+		// Check if this line opens a fenced code block
+		if indent < 4 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			flushText()
+			marker := trimmed[:1]
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, marker))]
+			output.WriteString(line)
+			prevBlank = false
+			continue
+		}
+
+		// Check if this line is part of indented code block, which can't interrupt
+		// paragraph and not used as continuation of list item.
+		isIndented := indent >= 4 || strings.HasPrefix(line, "\t")
+		if isIndented && !isBlank && !inList && (prevBlank || inIndentedCode) {
+			flushText()
+			output.WriteString(line)
+			inIndentedCode = true
+			prevBlank = false
+			continue
+		}
+
+		if !isBlank {
+			inIndentedCode = false
+			if !isIndented {
+				inList = isListItem(trimmed)
+			}
+		}
+
+		text.WriteString(line)
+		prevBlank = isBlank
+	}
+
+	flushText()
+	return output.Bytes()
+}
+
+// protectInlineMath replaces the math expressions in a text that not
+// contains code block. The code spans in the text are skipped.
+func protectInlineMath(src []byte, exprs *[]mathExpr) []byte {
+	output := bytes.Buffer{}
+	text := string(src)
+
+	addExpr := func(content string, display bool) {
+		fmt.Fprintf(&output, "SPOOKMATH%dX", len(*exprs))
+		*exprs = append(*exprs, mathExpr{
+			Content: strings.TrimSpace(content),
+			Display: display,
+		})
+	}
+
+	for i := 0; i < len(text); {
+		char := text[i]
+		rest := text[i:]
+
+		switch {
+		// \( ... \) and \[ ... \]
+		case strings.HasPrefix(rest, `\(`) || strings.HasPrefix(rest, `\[`):
+			closing := `\)`
+			if rest[1] == '[' {
+				closing = `\]`
+			}
+
+			end := strings.Index(rest[2:], closing)
+			if end == -1 {
+				output.WriteString(rest[:2])
+				i += 2
+				continue
+			}
+
+			addExpr(rest[2:2+end], rest[1] == '[')
+			i += 2 + end + 2
+
+		// Escaped character, e.g. \$
+		case char == '\\' && i+1 < len(text):
+			output.WriteString(rest[:2])
+			i += 2
+
+		// Code span, skip until the closing backticks with the same length
+		case char == '`':
+			n := len(rest) - len(strings.TrimLeft(rest, "`"))
+			end := findBackticks(rest[n:], n)
+			if end == -1 {
+				output.WriteString(rest[:n])
+				i += n
+				continue
+			}
+
+			output.WriteString(rest[:n+end+n])
+			i += n + end + n
+
+		// $$ ... $$
+		case strings.HasPrefix(rest, "$$"):
+			end := strings.Index(rest[2:], "$$")
+			if end == -1 {
+				output.WriteString("$$")
+				i += 2
+				continue
+			}
+
+			addExpr(rest[2:2+end], true)
+			i += 2 + end + 2
+
+		// $ ... $, which must not started or ended with space,
+		// and the closing $ must not be followed by digit.
+		case char == '$':
+			end := findInlineMathEnd(rest)
+			if end == -1 {
+				output.WriteByte(char)
+				i++
+				continue
+			}
+
+			addExpr(rest[1:end], false)
+			i += end + 1
+
+		default:
+			output.WriteByte(char)
+			i++
+		}
+	}
+
+	return output.Bytes()
+}
+
+// findInlineMathEnd returns the index of closing $ for the inline math
+// which started in the beginning of text. Returns -1 if not found.
+func findInlineMathEnd(text string) int {
+	if len(text) < 3 || isSpace(text[1]) {
+		return -1
+	}
+
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '`':
+			// Math can't contain code span
+			return -1
+		case '\n':
+			// Math can't cross paragraph, so stop in blank line
+			nextLine := text[i+1:]
+			if idx := strings.IndexByte(nextLine, '\n'); idx >= 0 {
+				nextLine = nextLine[:idx]
+			}
+
+			if strings.TrimSpace(nextLine) == "" {
+				return -1
+			}
+		case '$':
+			if isSpace(text[i-1]) {
+				continue
+			}
+
+			if i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9' {
+				continue
+			}
+
+			return i
+		}
+	}
+
+	return -1
+}
+
+// findBackticks returns the index of backticks run with length n.
+func findBackticks(text string, n int) int {
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+
+		runLength := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+		if runLength == n {
+			return i
+		}
+		i += runLength
+	}
+
+	return -1
+}
+
+// restoreMath replaces the placeholders in generated HTML with the math
+// expressions, wrapped in element with class "math" for client side rendering.
+func restoreMath(content []byte, exprs []mathExpr) []byte {
+	if len(exprs) == 0 {
+		return content
+	}
+
+	return rxMathPlaceholder.ReplaceAllFunc(content, func(match []byte) []byte {
+		parts := rxMathPlaceholder.FindSubmatch(match)
+		idx, err := strconv.Atoi(string(parts[1]))
+		if err != nil || idx >= len(exprs) {
+			return match
+		}
+
+		expr := exprs[idx]
+		isParagraph := bytes.HasPrefix(match, []byte("<p>")) && bytes.HasSuffix(match, []byte("</p>"))
+
+		var result string
+		switch {
+		case expr.Display && isParagraph:
+			result = `<div class="math">\[` + html.EscapeString(expr.Content) + `\]</div>`
+		case expr.Display:
+			result = `<span class="math display">\[` + html.EscapeString(expr.Content) + `\]</span>`
+		default:
+			result = `<span class="math">\(` + html.EscapeString(expr.Content) + `\)</span>`
+		}
+
+		// Put back the paragraph tag that not belong to this placeholder
+		if !isParagraph || !expr.Display {
+			if bytes.HasPrefix(match, []byte("<p>")) {
+				result = "<p>" + result
+			}
+
+			if bytes.HasSuffix(match, []byte("</p>")) {
+				result += "</p>"
+			}
+		}
+
+		return []byte(result)
+	})
+}
+
+// splitLines splits text into lines, with the line break kept.
+func splitLines(src []byte) []string {
+	lines := strings.SplitAfter(string(src), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// isListItem checks whether the line (without indentation) is a list item.
+func isListItem(line string) bool {
+	if len(line) >= 2 && strings.ContainsRune("-*+", rune(line[0])) && isSpace(line[1]) {
+		return true
+	}
+
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	return digits > 0 && len(line) > digits+1 &&
+		(line[digits] == '.' || line[digits] == ')') && isSpace(line[digits+1])
+}
+
+// isSpace checks whether the char is a whitespace.
+func isSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
+}
@@ -0,0 +1,84 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	rxShortcode    = regexp.MustCompile(`\{\{<\s*([\w-]+)((?:[^>]|>[^}])*?)\s*>\}\}`)
+	rxShortcodeArg = regexp.MustCompile(`(?:([\w-]+)\s*=\s*)?(?:"((?:[^"\\]|\\.)*)"|(\S+))`)
+)
+
+// Shortcode generates markdown content for shortcode, e.g. {{< include "main.go" >}}.
+// The named arguments are accessed by their name, while the positional arguments
+// are accessed by their index, e.g. args["0"] for the first positional argument.
+type Shortcode func(args map[string]string) (string, error)
+
+// expandShortcodes replaces the shortcodes inside markdown content with the markdown
+// that generated by the shortcode functions. The shortcodes inside code block and code
+// span are left untouched, so it can be used to show the usage of shortcode.
+func expandShortcodes(src []byte, shortcodes map[string]Shortcode) ([]byte, error) {
+	if len(shortcodes) == 0 {
+		return src, nil
+	}
+
+	var err error
+	output := transformText(src, func(text []byte) []byte {
+		return replaceOutsideCodeSpan(text, rxShortcode, func(match []byte) []byte {
+			parts := rxShortcode.FindSubmatch(match)
+			name := string(parts[1])
+
+			shortcode, exist := shortcodes[name]
+			if !exist {
+				if err == nil {
+					err = fmt.Errorf("unknown shortcode: %s", name)
+				}
+				return match
+			}
+
+			result, errShortcode := shortcode(parseShortcodeArgs(string(parts[2])))
+			if errShortcode != nil {
+				if err == nil {
+					err = fmt.Errorf("shortcode %s: %s", name, errShortcode)
+				}
+				return match
+			}
+
+			return []byte(result)
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// parseShortcodeArgs parses the arguments of shortcode, e.g. `"main.go" lines="3-5"`.
+func parseShortcodeArgs(src string) map[string]string {
+	args := map[string]string{}
+	nPositional := 0
+
+	for _, parts := range rxShortcodeArg.FindAllStringSubmatch(src, -1) {
+		value := parts[3]
+		if value == "" {
+			value = parts[2]
+			if unquoted, err := strconv.Unquote(`"` + parts[2] + `"`); err == nil {
+				value = unquoted
+			}
+		}
+
+		if parts[1] == "" {
+			args[strconv.Itoa(nPositional)] = value
+			nPositional++
+			continue
+		}
+
+		args[parts[1]] = value
+	}
+
+	return args
+}
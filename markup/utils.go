@@ -0,0 +1,154 @@
+package markup
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// writeCodeBlock writes code block with its language as class. The attributes in
+// info string (e.g. ```go {hl_lines="3-5"}) are written as data attributes.
+func writeCodeBlock(w io.Writer, info string, code string) {
+	lang, attributes := parseCodeInfo(info)
+
+	attrs := []string{}
+	if lang != "" {
+		attrs = append(attrs, fmt.Sprintf(`class="language-%s"`, html.EscapeString(lang)))
+	}
+
+	for _, name := range []string{"hl_lines", "linenos"} {
+		if value, exist := attributes[name]; exist {
+			attrName := "data-" + strings.Replace(name, "_", "-", -1)
+			attrs = append(attrs, fmt.Sprintf(`%s="%s"`, attrName, html.EscapeString(value)))
+		}
+	}
+
+	tag := "<code>"
+	if len(attrs) > 0 {
+		tag = "<code " + strings.Join(attrs, " ") + ">"
+	}
+
+	io.WriteString(w, "\n<pre>"+tag)
+	io.WriteString(w, html.EscapeString(code))
+	io.WriteString(w, "</code></pre>\n")
+}
+
+// parseCodeInfo parses info string of fenced code block, which consists of
+// language name and optional attributes, e.g. `go {hl_lines="3-5" linenos=true}`.
+func parseCodeInfo(info string) (string, map[string]string) {
+	info = strings.TrimSpace(info)
+	attributes := map[string]string{}
+
+	lang := info
+	if idx := strings.IndexAny(info, " \t{"); idx >= 0 {
+		lang = info[:idx]
+		info = info[idx:]
+	} else {
+		info = ""
+	}
+
+	start := strings.Index(info, "{")
+	end := strings.LastIndex(info, "}")
+	if start == -1 || end < start {
+		return lang, attributes
+	}
+
+	// Split attributes by space or comma, but keep the quoted value intact
+	fields := []string{}
+	current := ""
+	inQuote := false
+	for _, char := range info[start+1 : end] {
+		switch {
+		case char == '"':
+			inQuote = !inQuote
+			current += string(char)
+		case !inQuote && (char == ' ' || char == '\t' || char == ','):
+			if current != "" {
+				fields = append(fields, current)
+			}
+			current = ""
+		default:
+			current += string(char)
+		}
+	}
+
+	if current != "" {
+		fields = append(fields, current)
+	}
+
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		value := ""
+		if len(parts) == 2 {
+			value = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		}
+		attributes[key] = value
+	}
+
+	return lang, attributes
+}
+
+// admonitionTitles is the default title for each kind of admonition.
+var admonitionTitles = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+}
+
+// parseAdmonitionMarker parses GitHub-style admonition marker, e.g. [!NOTE], in the
+// beginning of text. Returns the kind of admonition, its title and the rest of text.
+func parseAdmonitionMarker(text string) (kind, title, rest string, ok bool) {
+	if !strings.HasPrefix(text, "[!") {
+		return "", "", "", false
+	}
+
+	end := strings.Index(text, "]")
+	if end == -1 {
+		return "", "", "", false
+	}
+
+	kind = strings.ToLower(text[2:end])
+	title, exist := admonitionTitles[kind]
+	if !exist {
+		return "", "", "", false
+	}
+
+	firstLine := text[end+1:]
+	if idx := strings.IndexByte(firstLine, '\n'); idx >= 0 {
+		rest = firstLine[idx+1:]
+		firstLine = firstLine[:idx]
+	}
+
+	if customTitle := strings.TrimSpace(firstLine); customTitle != "" {
+		title = customTitle
+	}
+
+	return kind, title, rest, true
+}
+
+// parseTaskMarker parses task marker, i.e. [ ] or [x], in the beginning
+// of list item. Returns whether the task is checked and the rest of text.
+func parseTaskMarker(text string) (checked bool, rest string, ok bool) {
+	if len(text) < 3 || text[0] != '[' || text[2] != ']' {
+		return false, "", false
+	}
+
+	if len(text) > 3 && !isSpace(text[3]) {
+		return false, "", false
+	}
+
+	switch text[1] {
+	case ' ':
+		checked = false
+	case 'x', 'X':
+		checked = true
+	default:
+		return false, "", false
+	}
+
+	return checked, strings.TrimLeft(text[3:], " \t"), true
+}
@@ -0,0 +1,102 @@
+package markup
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var rxWikiLink = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]+))?\]\]`)
+
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`, `[`, `\[`, `]`, `\]`,
+	`*`, `\*`, `_`, `\_`, "`", "\\`",
+)
+
+// WikiLinks returns the targets of wiki links, i.e. [[target]] or [[target|label]],
+// inside markdown content. The wiki links inside code block and code span are ignored.
+func WikiLinks(src []byte) []string {
+	targets := []string{}
+	transformText(src, func(text []byte) []byte {
+		return replaceOutsideCodeSpan(text, rxWikiLink, func(match []byte) []byte {
+			parts := rxWikiLink.FindSubmatch(match)
+			targets = append(targets, strings.TrimSpace(string(parts[1])))
+			return match
+		})
+	})
+
+	return targets
+}
+
+// replaceWikiLinks replaces the wiki links inside markdown content with the
+// usual markdown links, using resolver to find the URL and title of the target.
+// If resolver is nil, the wiki link is replaced by its label as plain text.
+func replaceWikiLinks(src []byte, resolver WikiLinkResolver) ([]byte, error) {
+	var err error
+	output := transformText(src, func(text []byte) []byte {
+		return replaceOutsideCodeSpan(text, rxWikiLink, func(match []byte) []byte {
+			parts := rxWikiLink.FindSubmatch(match)
+			target := strings.TrimSpace(string(parts[1]))
+			label := strings.TrimSpace(string(parts[2]))
+
+			if resolver == nil {
+				if label == "" {
+					return []byte(mdEscaper.Replace(target))
+				}
+				return []byte(label)
+			}
+
+			resolvedURL, title, errResolve := resolver(target)
+			if errResolve != nil {
+				if err == nil {
+					err = errResolve
+				}
+				return match
+			}
+
+			if label == "" {
+				label = mdEscaper.Replace(title)
+			}
+
+			dst := (&url.URL{Path: resolvedURL}).EscapedPath()
+			return []byte("[" + label + "](" + dst + ")")
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// replaceOutsideCodeSpan replaces the matches of rx in a text that not contains
+// code block using function fn. The code spans in the text are left untouched.
+func replaceOutsideCodeSpan(text []byte, rx *regexp.Regexp, fn func(match []byte) []byte) []byte {
+	output := bytes.Buffer{}
+
+	for len(text) > 0 {
+		start := bytes.IndexByte(text, '`')
+		if start == -1 {
+			output.Write(rx.ReplaceAllFunc(text, fn))
+			break
+		}
+
+		output.Write(rx.ReplaceAllFunc(text[:start], fn))
+		text = text[start:]
+
+		n := len(text) - len(bytes.TrimLeft(text, "`"))
+		end := findBackticks(string(text[n:]), n)
+		if end == -1 {
+			output.Write(text[:n])
+			text = text[n:]
+			continue
+		}
+
+		output.Write(text[:n+end+n])
+		text = text[n+end+n:]
+	}
+
+	return output.Bytes()
+}
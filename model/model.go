@@ -1,5 +1,11 @@
 package model
 
+import "io/fs"
+
+// Version is the current version of Spook.
+// It's used to check whether a theme is compatible or not.
+const Version = "0.1.0"
+
 // Config is data of main configuration file
 type Config struct {
 	Title       string
@@ -7,6 +13,72 @@ type Config struct {
 	Owner       string
 	Pagination  int
 	Theme       string
+	Params      map[string]interface{}
+	Markup      MarkupConfig
+	Image       ImageConfig
+	Assets      AssetConfig
+}
+
+// MarkupConfig is configuration for converting markdown into HTML
+type MarkupConfig struct {
+	Engine       string
+	Typographer  bool
+	Highlight    HighlightConfig
+	Heading      HeadingConfig
+	Transformers []string
+}
+
+// HighlightConfig is configuration for syntax highlighting in code block
+type HighlightConfig struct {
+	Style       string
+	InlineStyle bool
+	LineNumbers bool
+	TabWidth    int
+}
+
+// HeadingConfig is configuration for ID and anchor link of headings
+type HeadingConfig struct {
+	IDStrategy     string
+	Anchor         bool
+	AnchorSymbol   string
+	AnchorPosition string
+	AnchorLevels   []int
+}
+
+// ImageConfig is configuration for generating resized variants of images.
+// The variants are only generated for the widths that smaller than the original.
+// The GPS location in EXIF is only exposed to templates if ExposeGPS is true.
+type ImageConfig struct {
+	Widths          []int
+	ThumbnailWidths []int
+	Quality         int
+	StripEXIF       bool
+	ExposeGPS       bool
+}
+
+// AssetConfig is configuration for processing the asset files in theme, e.g. CSS and JS.
+// Bundles maps the name of bundle to the list of files that concatenated into it, e.g.
+// "css/main.css" = ["css/reset.css", "css/style.css"]. The paths are relative to theme dir.
+type AssetConfig struct {
+	Minify      bool
+	Fingerprint bool
+	Bundles     map[string][]string
+}
+
+// Theme is data of theme manifest file. Path is the slash separated path of theme dir
+// inside the file system it's opened from, while FS is the file system of theme dir.
+type Theme struct {
+	Name        string
+	Description string
+	Author      string
+	License     string
+	MinVersion  string
+	Parent      string
+	Templates   []string
+	Params      map[string]interface{}
+	Bundles     map[string][]string
+	Path        string `toml:"-"`
+	FS          fs.FS  `toml:"-" json:"-"`
 }
 
 // Group is keyword for grouping several posts
@@ -18,21 +90,37 @@ type Group struct {
 
 // Page is a static standalone content
 type Page struct {
-	Title     string
-	Excerpt   string
-	Path      string `toml:"-"`
-	Thumbnail string `toml:"-"`
+	Title      string
+	Slug       string
+	Excerpt    string
+	Path       string   `toml:"-"`
+	Thumbnail  string   `toml:"-"`
+	References []string `toml:"-"`
+	Content    *Content `toml:"-" json:"-"`
 }
 
 // Post is the content that listed in chronological order
 type Post struct {
-	Title     string
-	Excerpt   string
-	CreatedAt string
-	UpdatedAt string
-	Category  string
-	Tags      []string
-	Author    string
-	Path      string `toml:"-"`
-	Thumbnail string `toml:"-"`
+	Title      string
+	Slug       string
+	Excerpt    string
+	CreatedAt  string
+	UpdatedAt  string
+	Category   string
+	Tags       []string
+	Author     string
+	Path       string   `toml:"-"`
+	Thumbnail  string   `toml:"-"`
+	References []string `toml:"-"`
+	Content    *Content `toml:"-" json:"-"`
+}
+
+// Content is the content of post or page, which parsed once from its index file.
+type Content struct {
+	Markdown  []byte
+	HTML      string
+	TOC       string
+	HasMath   bool
+	PlainText string
+	Summary   string
 }
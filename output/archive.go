@@ -0,0 +1,156 @@
+package output
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the format of archive that written by Archive.
+type ArchiveFormat int
+
+const (
+	// Tar means the archive is an uncompressed tar.
+	Tar ArchiveFormat = iota
+	// TarGzip means the archive is a tar compressed by gzip.
+	TarGzip
+	// Zip means the archive is a zip.
+	Zip
+)
+
+// DefaultModTime is the default limit for modification time of archive entries. It's
+// the earliest time that supported by zip, so it's usable in every format.
+var DefaultModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ArchiveFormatOf returns the archive format from extension of the file name,
+// i.e. ".tar", ".tar.gz", ".tgz" or ".zip". Returns false if it's not an archive.
+func ArchiveFormatOf(name string) (ArchiveFormat, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return Tar, true
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGzip, true
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, true
+	default:
+		return 0, false
+	}
+}
+
+// Archive is a sink that keeps the files in memory, then writes them as an archive.
+// The entries are sorted by their name, and their modification time is clamped to
+// ModTime, so the same files always produce a byte-identical archive.
+type Archive struct {
+	*Memory
+	Format  ArchiveFormat
+	ModTime time.Time
+}
+
+// NewArchive returns an empty archive sink in the format.
+func NewArchive(format ArchiveFormat) *Archive {
+	return &Archive{
+		Memory:  NewMemory(),
+		Format:  format,
+		ModTime: DefaultModTime,
+	}
+}
+
+// WriteTo writes the files as archive into w, then returns the number of written bytes.
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	counter := &countWriter{w: w}
+
+	var err error
+	switch a.Format {
+	case Tar:
+		err = a.writeTar(counter)
+	case TarGzip:
+		gz := gzip.NewWriter(counter)
+		err = a.writeTar(gz)
+		if errClose := gz.Close(); err == nil {
+			err = errClose
+		}
+	case Zip:
+		err = a.writeZip(counter)
+	default:
+		err = fmt.Errorf("archive format %d is not supported", a.Format)
+	}
+
+	return counter.n, err
+}
+
+func (a *Archive) writeTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, name := range a.Names() {
+		content, _ := a.File(name)
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  a.entryModTime(name),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+func (a *Archive) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, name := range a.Names() {
+		content, _ := a.File(name)
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: a.entryModTime(name),
+		}
+		header.SetMode(0644)
+
+		f, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Write(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// entryModTime returns the modification time of file with the name, which is
+// replaced by ModTime if it's after ModTime or unknown.
+func (a *Archive) entryModTime(name string) time.Time {
+	modTime, _ := a.Memory.ModTime(name)
+	if modTime.IsZero() || modTime.After(a.ModTime) {
+		modTime = a.ModTime
+	}
+
+	return modTime.UTC()
+}
+
+// countWriter counts the bytes that written into w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
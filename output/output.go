@@ -0,0 +1,174 @@
+// Package output provides the destinations that the built site is written into.
+package output
+
+import (
+	"bytes"
+	"io"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink is the destination of the files that written while building the site. The name
+// of file is slash separated path relative to the root of output, e.g. "post/hello/index.html".
+// Sink must be safe for concurrent use, since the files are written by many workers.
+type Sink interface {
+	// Create creates the file with the name, replacing it if it's already exist. The
+	// file is only complete after it's closed, and has modTime as its modification time.
+	Create(name string, modTime time.Time) (io.WriteCloser, error)
+}
+
+// Dir is a sink that writes the files into a directory. Since the written files are
+// kept between builds, it can be used for incremental build.
+type Dir struct {
+	Path string
+}
+
+// NewDir returns a sink that writes the files into directory in path.
+func NewDir(path string) *Dir {
+	return &Dir{Path: path}
+}
+
+// Create creates the file with the name inside the directory, along with its parent dirs.
+// The modification time is set when the file is closed, unless it's zero.
+func (d *Dir) Create(name string, modTime time.Time) (io.WriteCloser, error) {
+	filePath := d.path(name)
+	err := os.MkdirAll(fp.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirFile{File: f, modTime: modTime}, nil
+}
+
+// Exists checks if file with the name exists inside the directory.
+func (d *Dir) Exists(name string) bool {
+	f, err := os.Stat(d.path(name))
+	return err == nil && !f.IsDir()
+}
+
+// Remove removes the file with the name, along with its parent dirs that become empty.
+func (d *Dir) Remove(name string) error {
+	filePath := d.path(name)
+	err := os.Remove(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for dir := fp.Dir(filePath); dir != d.Path && strings.HasPrefix(dir, d.Path); dir = fp.Dir(dir) {
+		if !isEmpty(dir) || os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Clean removes all files inside the directory, except the hidden files,
+// e.g. .git, and CNAME file which used by Github pages.
+func (d *Dir) Clean() error {
+	return removeDirContents(d.Path)
+}
+
+func (d *Dir) path(name string) string {
+	return fp.Join(d.Path, fp.FromSlash(name))
+}
+
+// dirFile is a file that created in directory sink.
+type dirFile struct {
+	*os.File
+	modTime time.Time
+}
+
+// Close closes the file, then sets its modification time.
+func (f *dirFile) Close() error {
+	err := f.File.Close()
+	if err != nil || f.modTime.IsZero() {
+		return err
+	}
+
+	return os.Chtimes(f.Name(), f.modTime, f.modTime)
+}
+
+// Memory is a sink that keeps the files in memory, e.g. to
+// check the built site without writing it into the disk.
+type Memory struct {
+	mutex sync.Mutex
+	files map[string]memoryEntry
+}
+
+// memoryEntry is the content and modification time of file in memory sink.
+type memoryEntry struct {
+	content []byte
+	modTime time.Time
+}
+
+// NewMemory returns an empty memory sink.
+func NewMemory() *Memory {
+	return &Memory{files: map[string]memoryEntry{}}
+}
+
+// Create creates the file with the name, which saved when it's closed.
+func (m *Memory) Create(name string, modTime time.Time) (io.WriteCloser, error) {
+	return &memoryFile{sink: m, name: name, modTime: modTime}, nil
+}
+
+// File returns the content of file with the name.
+func (m *Memory) File(name string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, exist := m.files[name]
+	return entry.content, exist
+}
+
+// ModTime returns the modification time of file with the name.
+func (m *Memory) ModTime(name string) (time.Time, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, exist := m.files[name]
+	return entry.modTime, exist
+}
+
+// Names returns the name of all files, sorted alphabetically.
+func (m *Memory) Names() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := []string{}
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// memoryFile is a file that created in memory sink.
+type memoryFile struct {
+	bytes.Buffer
+	sink    *Memory
+	name    string
+	modTime time.Time
+}
+
+// Close saves the content of file into its sink.
+func (f *memoryFile) Close() error {
+	f.sink.mutex.Lock()
+	defer f.sink.mutex.Unlock()
+
+	f.sink.files[f.name] = memoryEntry{
+		content: f.Bytes(),
+		modTime: f.modTime,
+	}
+	return nil
+}
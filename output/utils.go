@@ -0,0 +1,60 @@
+package output
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+)
+
+// isEmpty checks if a directory is empty or not.
+func isEmpty(dirPath string) bool {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+
+	_, err = dir.Readdirnames(1)
+	if err != io.EOF {
+		return false
+	}
+
+	return true
+}
+
+func removeDirContents(dirPath string) error {
+	dirItems, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, dirItem := range dirItems {
+		// Skip hidden file or directory (like .git)
+		if strings.HasPrefix(dirItem.Name(), ".") {
+			continue
+		}
+
+		// Skip CNAME as well, since it's used for Github pages
+		if strings.ToLower(dirItem.Name()) == "cname" {
+			continue
+		}
+
+		dirItemPath := fp.Join(dirPath, dirItem.Name())
+		if dirItem.IsDir() {
+			err = removeDirContents(dirItemPath)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = os.Remove(dirItemPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
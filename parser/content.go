@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-spook/spook/markup"
+	"github.com/go-spook/spook/model"
+)
+
+// Converter converts markdown of the post or page in contentPath into HTML.
+// The returned content only needs to have its HTML, TOC and HasMath set.
+type Converter func(contentPath string, markdown []byte) (model.Content, error)
+
+// ParseContent converts the markdown of post or page in contentPath using the converter,
+// then extracts the plain text and summary from the HTML. If converter is nil, the
+// markdown is converted using the engine in config without any options.
+func (ps Parser) ParseContent(contentPath string, markdown []byte, convert Converter) (*model.Content, error) {
+	if convert == nil {
+		convert = ps.convertMarkdown
+	}
+
+	content, err := convert(contentPath, markdown)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content.HTML))
+	if err != nil {
+		return nil, err
+	}
+
+	content.Markdown = markdown
+	content.PlainText = strings.TrimSpace(doc.Text())
+	content.Summary = getFirstParagraph(doc)
+	return &content, nil
+}
+
+// convertMarkdown converts markdown into HTML using the engine in config.
+func (ps Parser) convertMarkdown(contentPath string, markdown []byte) (model.Content, error) {
+	engine, err := markup.New(ps.Config.Markup)
+	if err != nil {
+		return model.Content{}, err
+	}
+
+	result, err := markup.Convert(engine, markdown, markup.Options{})
+	if err != nil {
+		return model.Content{}, err
+	}
+
+	return model.Content{
+		HTML:    string(result.HTML),
+		HasMath: result.HasMath,
+	}, nil
+}
+
+// getFirstParagraph fetch the first paragraph from the HTML of content.
+// It will be used as default excerpt if user doesn't specify it.
+func getFirstParagraph(doc *goquery.Document) string {
+	p := doc.Find("p").First().Text()
+	return strings.Join(strings.Fields(p), " ")
+}
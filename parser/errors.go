@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var rxTOMLError = regexp.MustCompile(`^Near line (\d+) \(last key parsed '[^']*'\): `)
+
+// ContentError is an error in the index file of post or page, e.g. invalid metadata.
+// Path is relative to root dir, while Line and Column are zero if they're unknown.
+type ContentError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error returns the error message, prefixed by its location.
+func (e ContentError) Error() string {
+	location := e.Path
+	if e.Line > 0 {
+		location += fmt.Sprintf(":%d", e.Line)
+		if e.Column > 0 {
+			location += fmt.Sprintf(":%d", e.Column)
+		}
+	}
+
+	return location + ": " + e.Message
+}
+
+// ContentErrors is list of errors in contents. It's returned by parser along with the
+// valid posts and pages, so the caller can decide whether to skip the invalid contents.
+type ContentErrors []ContentError
+
+// Error returns all error messages, one in each line.
+func (errs ContentErrors) Error() string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Paths returns the path of invalid contents.
+func (errs ContentErrors) Paths() []string {
+	paths := []string{}
+	visited := map[string]struct{}{}
+	for _, err := range errs {
+		if _, exist := visited[err.Path]; !exist {
+			visited[err.Path] = struct{}{}
+			paths = append(paths, err.Path)
+		}
+	}
+
+	return paths
+}
+
+// indexFileError converts error while reading index file into content error.
+func indexFileError(path string, err error) ContentError {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ContentError{Path: path, Message: "index file is not exist"}
+	}
+
+	return ContentError{Path: path, Message: fmt.Sprintf("failed to read index file: %s", err)}
+}
+
+// metadataError converts error from TOML decoder into content error.
+// Since the metadata is started after the "+++" line, the line is shifted by one.
+func metadataError(path string, err error) ContentError {
+	msg := err.Error()
+	if parts := rxTOMLError.FindStringSubmatch(msg); parts != nil {
+		line, _ := strconv.Atoi(parts[1])
+		return ContentError{
+			Path:    path,
+			Line:    line + 1,
+			Message: "unable to parse metadata: " + strings.TrimPrefix(msg, parts[0]),
+		}
+	}
+
+	return ContentError{
+		Path:    path,
+		Line:    1,
+		Message: "unable to parse metadata: " + msg,
+	}
+}
+
+// keyLocation returns the line and column of value of the metadata key in content.
+// Returns the location of metadata separator if the key is not found.
+func keyLocation(content []byte, key string) (int, int) {
+	rxKey := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*=\s*`)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line > 1 && text == "+++" {
+			break
+		}
+
+		if loc := rxKey.FindStringIndex(text); loc != nil {
+			return line, loc[1] + 1
+		}
+	}
+
+	return 1, 1
+}
@@ -2,20 +2,23 @@ package parser
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io/fs"
+	"path"
 	fp "path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/go-spook/spook/markup"
 	"github.com/go-spook/spook/model"
 )
 
-// Parser is used to parse markdown files to get the posts,
-// pages, categories and tags that used in the blog.
+// Parser is used to parse markdown files to get the posts, pages, categories
+// and tags that used in the blog. The files are read from FS, which is the
+// file system of the site root dir.
 type Parser struct {
-	Config  model.Config
-	RootDir string
+	Config model.Config
+	FS     fs.FS
 }
 
 // ParsedPosts is the output from ParsePosts
@@ -27,6 +30,8 @@ type ParsedPosts struct {
 
 // ParsePosts parse all posts inside the post directory.
 // Returns all posts, also categories and tags that used in the posts.
+// If some posts are invalid, they are skipped and the returned error is
+// ContentErrors, which contains the location of every error.
 func (ps Parser) ParsePosts() (output ParsedPosts, err error) {
 	// The valid posts must be structured like this :
 	// <root-dir>
@@ -39,15 +44,14 @@ func (ps Parser) ParsePosts() (output ParsedPosts, err error) {
 	//     `-- 2006-02-03-post-name-2
 
 	// Scan and parse all posts.
-	postDir := fp.Join(ps.RootDir, "post")
-	dirItems, err := ioutil.ReadDir(postDir)
+	dirItems, err := fs.ReadDir(ps.FS, "post")
 	if err != nil {
 		return output, fmt.Errorf("failed to scan post dir: %s", err)
 	}
 
 	posts := []model.Post{}
-	mapTag := map[string]int{}
-	mapCategory := map[string]int{}
+	contentErrors := ContentErrors{}
+	createTimes := map[string]time.Time{}
 
 	for _, item := range dirItems {
 		if !item.IsDir() {
@@ -55,54 +59,119 @@ func (ps Parser) ParsePosts() (output ParsedPosts, err error) {
 		}
 
 		// Open and read index file
-		itemDir := fp.Join(postDir, item.Name())
-		content, err := readIndexFile(itemDir)
+		itemDir := path.Join("post", item.Name())
+		indexPath := path.Join(itemDir, "_index.md")
+		rawContent, err := fs.ReadFile(ps.FS, indexPath)
 		if err != nil {
-			return output, fmt.Errorf("failed to read index file for %s: %s", item.Name(), err)
+			contentErrors = append(contentErrors, indexFileError(indexPath, err))
+			continue
 		}
 
 		// Split metadata and content
 		post := model.Post{}
-		content, err = readMetadata(content, &post)
+		content, err := readMetadata(indexPath, rawContent, &post)
 		if err != nil {
-			return output, fmt.Errorf("failed to parse metadata for %s: %s", item.Name(), err)
+			contentErrors = append(contentErrors, err.(ContentError))
+			continue
 		}
 
-		// Make sure title is not empty
+		// Make sure title is not empty and date time format is correct.
+		// All errors in the metadata are collected before skipping the post.
+		nErrors := len(contentErrors)
 		if post.Title == "" {
-			return output, fmt.Errorf("title is not defined in %s", item.Name())
+			contentErrors = append(contentErrors, ContentError{
+				Path:    indexPath,
+				Line:    1,
+				Column:  1,
+				Message: "title is not defined",
+			})
 		}
 
-		// Make sure date time format is correct
+		updatedKey := "UpdatedAt"
 		if post.UpdatedAt == "" {
 			post.UpdatedAt = post.CreatedAt
+			updatedKey = "CreatedAt"
+		}
+
+		createTime, err := time.Parse("2006-01-02 15:04:05 -0700", post.CreatedAt)
+		if err != nil {
+			line, column := keyLocation(rawContent, "CreatedAt")
+			contentErrors = append(contentErrors, ContentError{
+				Path:    indexPath,
+				Line:    line,
+				Column:  column,
+				Message: fmt.Sprintf("failed to parse create time: %s", err),
+			})
 		}
 
-		if _, err = time.Parse("2006-01-02 15:04:05 -0700", post.CreatedAt); err != nil {
-			return output, fmt.Errorf("failed to parse create time for %s: %s", item.Name(), err)
+		if _, err = time.Parse("2006-01-02 15:04:05 -0700", post.UpdatedAt); err != nil && updatedKey == "UpdatedAt" {
+			line, column := keyLocation(rawContent, updatedKey)
+			contentErrors = append(contentErrors, ContentError{
+				Path:    indexPath,
+				Line:    line,
+				Column:  column,
+				Message: fmt.Sprintf("failed to parse update time: %s", err),
+			})
 		}
 
-		if _, err = time.Parse("2006-01-02 15:04:05 -0700", post.UpdatedAt); err != nil {
-			return output, fmt.Errorf("failed to parse update time for %s: %s", item.Name(), err)
+		if len(contentErrors) > nErrors {
+			continue
 		}
 
 		// Set post's path
 		post.Path = fp.Join("/", "post", item.Name())
+		createTimes[post.Path] = createTime
 
 		// Get post's thumbnail
-		thumbnailName := getThumbnailFile(itemDir)
+		thumbnailName := getThumbnailFile(ps.FS, itemDir)
 		if thumbnailName != "" {
 			post.Thumbnail = fp.Join(post.Path, thumbnailName)
 		}
 
-		// If it doesn't have any excerpt, pick the first paragraph
-		if post.Excerpt == "" {
-			post.Excerpt = getFirstParagraph(content)
-		}
+		// Keep the markdown, which converted later by ParseContent in the convert phase of site
+		post.Content = &model.Content{Markdown: content}
+
+		// Save the targets of wiki links, which used to find backlinks
+		post.References = markup.WikiLinks(content)
 
 		// Save parse result
 		posts = append(posts, post)
+	}
+
+	categories, tags := Groups(posts)
+
+	// Sort posts from the newest. The posts that created at the same
+	// time are sorted by their path, so the order is always the same.
+	sort.Slice(posts, func(i int, j int) bool {
+		iTime := createTimes[posts[i].Path]
+		jTime := createTimes[posts[j].Path]
+		if !iTime.Equal(jTime) {
+			return iTime.After(jTime)
+		}
+		return posts[i].Path < posts[j].Path
+	})
+
+	// Finished
+	output = ParsedPosts{
+		Posts:      posts,
+		Categories: categories,
+		Tags:       tags,
+	}
 
+	if len(contentErrors) > 0 {
+		return output, contentErrors
+	}
+
+	return output, nil
+}
+
+// Groups returns the categories and tags of posts, sorted by their name.
+// The posts without category are grouped into category with empty name.
+func Groups(posts []model.Post) ([]model.Group, []model.Group) {
+	mapTag := map[string]int{}
+	mapCategory := map[string]int{}
+
+	for _, post := range posts {
 		category := strings.TrimSpace(post.Category)
 		mapCategory[category]++
 
@@ -115,9 +184,9 @@ func (ps Parser) ParsePosts() (output ParsedPosts, err error) {
 		}
 	}
 
-	// Convert map category and tag to slice
+	// Convert map category and tag to slice, sorted by their name
 	categories := []model.Group{}
-	for category, n := range mapCategory {
+	for _, category := range sortedKeys(mapCategory) {
 		pathName := category
 		if pathName == "" {
 			pathName = "uncategorized"
@@ -126,45 +195,24 @@ func (ps Parser) ParsePosts() (output ParsedPosts, err error) {
 		categories = append(categories, model.Group{
 			Name:   category,
 			Path:   fp.Join("/", "category", pathName),
-			NPosts: n,
+			NPosts: mapCategory[category],
 		})
 	}
 
 	tags := []model.Group{}
-	for tag, n := range mapTag {
+	for _, tag := range sortedKeys(mapTag) {
 		tags = append(tags, model.Group{
 			Name:   tag,
 			Path:   fp.Join("/", "tag", tag),
-			NPosts: n,
+			NPosts: mapTag[tag],
 		})
 	}
 
-	// Sort list category, tag and post
-	sort.Slice(posts, func(i int, j int) bool {
-		iTime, _ := time.Parse("2006-01-02 15:04:05 -0700", posts[i].CreatedAt)
-		jTime, _ := time.Parse("2006-01-02 15:04:05 -0700", posts[j].CreatedAt)
-		return iTime.After(jTime)
-	})
-
-	sort.Slice(categories, func(i int, j int) bool {
-		return categories[i].Name < categories[j].Name
-	})
-
-	sort.Slice(tags, func(i int, j int) bool {
-		return tags[i].Name < tags[j].Name
-	})
-
-	// Finished
-	output = ParsedPosts{
-		Posts:      posts,
-		Categories: categories,
-		Tags:       tags,
-	}
-
-	return output, nil
+	return categories, tags
 }
 
-// ParsePages parse all pages inside the page directory
+// ParsePages parse all pages inside the page directory. Like ParsePosts, the
+// invalid pages are skipped and the returned error is ContentErrors.
 func (ps Parser) ParsePages() (pages []model.Page, err error) {
 	// The valid pages must be structured like this :
 	// <root-dir>
@@ -175,60 +223,78 @@ func (ps Parser) ParsePages() (pages []model.Page, err error) {
 	//     `-- page-2
 
 	// Scan and parse all pages
-	pageDir := fp.Join(ps.RootDir, "page")
-	dirItems, err := ioutil.ReadDir(pageDir)
+	dirItems, err := fs.ReadDir(ps.FS, "page")
 	if err != nil {
 		return nil, err
 	}
 
 	pages = []model.Page{}
+	contentErrors := ContentErrors{}
 	for _, item := range dirItems {
 		if !item.IsDir() {
 			continue
 		}
 
 		// Open and read index file
-		itemDir := fp.Join(pageDir, item.Name())
-		content, err := readIndexFile(itemDir)
+		itemDir := path.Join("page", item.Name())
+		indexPath := path.Join(itemDir, "_index.md")
+		rawContent, err := fs.ReadFile(ps.FS, indexPath)
 		if err != nil {
-			return pages, fmt.Errorf("failed to read index file for %s: %s", item.Name(), err)
+			contentErrors = append(contentErrors, indexFileError(indexPath, err))
+			continue
 		}
 
 		// Split metadata and content
 		page := model.Page{}
-		content, err = readMetadata(content, &page)
+		content, err := readMetadata(indexPath, rawContent, &page)
 		if err != nil {
-			return pages, fmt.Errorf("failed to parse metadata for %s: %s", item.Name(), err)
+			contentErrors = append(contentErrors, err.(ContentError))
+			continue
 		}
 
 		// Make sure title is not empty
 		if page.Title == "" {
-			return pages, fmt.Errorf("title is not defined in %s", item.Name())
+			contentErrors = append(contentErrors, ContentError{
+				Path:    indexPath,
+				Line:    1,
+				Column:  1,
+				Message: "title is not defined",
+			})
+			continue
 		}
 
 		// Set page's path
 		page.Path = fp.Join("/", "page", item.Name())
 
 		// Get page's thumbnail
-		thumbnailName := getThumbnailFile(itemDir)
+		thumbnailName := getThumbnailFile(ps.FS, itemDir)
 		if thumbnailName != "" {
 			page.Thumbnail = fp.Join(page.Path, thumbnailName)
 		}
 
-		// If it doesn't have any excerpt, pick the first paragraph
-		if page.Excerpt == "" {
-			page.Excerpt = getFirstParagraph(content)
-		}
+		// Keep the markdown, which converted later by ParseContent in the convert phase of site
+		page.Content = &model.Content{Markdown: content}
+
+		// Save the targets of wiki links, which used to find backlinks
+		page.References = markup.WikiLinks(content)
 
 		// Save parse result
 		pages = append(pages, page)
 	}
 
-	// Sort list page
-	sort.Slice(pages, func(i int, j int) bool {
-		return pages[i].Title < pages[j].Title
+	// Sort list page by their title. The pages with the same
+	// title are sorted by their path, so the order is always the same.
+	sort.SliceStable(pages, func(i int, j int) bool {
+		if pages[i].Title != pages[j].Title {
+			return pages[i].Title < pages[j].Title
+		}
+		return pages[i].Path < pages[j].Path
 	})
 
 	// Finished
+	if len(contentErrors) > 0 {
+		return pages, contentErrors
+	}
+
 	return pages, nil
 }
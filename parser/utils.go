@@ -2,41 +2,28 @@ package parser
 
 import (
 	"bytes"
-	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"net/http"
-	"os"
-	fp "path/filepath"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/BurntSushi/toml"
-	"github.com/PuerkitoBio/goquery"
-	blackfriday "gopkg.in/russross/blackfriday.v2"
 )
 
-// readIndexFile reads content of _index.md file in specified directory
-func readIndexFile(dir string) ([]byte, error) {
-	indexFile, err := os.Open(fp.Join(dir, "_index.md"))
-	if err != nil {
-		return nil, err
-	}
-	defer indexFile.Close()
-
-	return ioutil.ReadAll(indexFile)
-}
-
 // readMetadata fetch metadata from specified content, put it to the
 // specified destination, and returns final content without the metadata.
-func readMetadata(content []byte, dst interface{}) ([]byte, error) {
+// The returned error is ContentError for the index file in path.
+func readMetadata(path string, content []byte, dst interface{}) ([]byte, error) {
 	// Separate metadata and content
 	if !bytes.HasPrefix(content, []byte("+++\n")) {
-		return nil, fmt.Errorf("Content is not started with metadata")
+		return nil, ContentError{Path: path, Line: 1, Column: 1, Message: "content is not started with metadata"}
 	}
 
 	content = bytes.TrimPrefix(content, []byte("+++\n"))
 	separatorIdx := bytes.Index(content, []byte("+++\n"))
 	if separatorIdx == -1 {
-		return nil, fmt.Errorf("Content is not started with metadata")
+		return nil, ContentError{Path: path, Line: 1, Column: 1, Message: "metadata is not closed by +++"}
 	}
 
 	metadata := content[:separatorIdx]
@@ -45,15 +32,15 @@ func readMetadata(content []byte, dst interface{}) ([]byte, error) {
 	// Parse metadata
 	_, err := toml.Decode(string(metadata), dst)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to parse metadata: %s", err)
+		return nil, metadataError(path, err)
 	}
 
 	return content, nil
 }
 
-// getThumbnailFile fetch thumbnail file in specified directory
-func getThumbnailFile(dir string) string {
-	items, err := ioutil.ReadDir(dir)
+// getThumbnailFile fetch thumbnail file in specified directory of fsys
+func getThumbnailFile(fsys fs.FS, dir string) string {
+	items, err := fs.ReadDir(fsys, dir)
 	if err != nil {
 		return ""
 	}
@@ -64,8 +51,8 @@ func getThumbnailFile(dir string) string {
 		}
 
 		if strings.HasPrefix(item.Name(), "_thumbnail.") {
-			imgPath := fp.Join(dir, item.Name())
-			if isImageFile(imgPath) {
+			imgPath := path.Join(dir, item.Name())
+			if isImageFile(fsys, imgPath) {
 				return item.Name()
 			}
 		}
@@ -75,9 +62,9 @@ func getThumbnailFile(dir string) string {
 }
 
 // isImageFile check file's header and see if it is image
-func isImageFile(path string) bool {
+func isImageFile(fsys fs.FS, name string) bool {
 	// Open file
-	f, err := os.Open(path)
+	f, err := fsys.Open(name)
 	if err != nil {
 		return false
 	}
@@ -95,15 +82,13 @@ func isImageFile(path string) bool {
 	return strings.HasPrefix(mimeType, "image/")
 }
 
-// getFirstParagraph fetch the first paragraph from a markdown content.
-// It will be used as default excerpt if user doesn't specify it.
-func getFirstParagraph(content []byte) string {
-	html := blackfriday.Run(content)
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
-	if err != nil {
-		return ""
+// sortedKeys returns the keys of map, sorted alphabetically.
+func sortedKeys(m map[string]int) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	p := doc.Find("p").First().Text()
-	return strings.Join(strings.Fields(p), " ")
+	return keys
 }
@@ -1,8 +1,14 @@
 package renderer
 
 import (
+	"fmt"
+	"html/template"
+	fp "path/filepath"
 	"strings"
 	"time"
+
+	"github.com/go-spook/spook/asset"
+	"github.com/go-spook/spook/imaging"
 )
 
 func add(a, b int) int {
@@ -43,3 +49,65 @@ func limitSentence(src string, n int) string {
 
 	return src[:nWords]
 }
+
+// funcsMap returns the functions that can be used in templates, including
+// the ones that need the renderer, e.g. to generate resized images.
+func (rd Renderer) funcsMap() template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, fn := range funcsMap {
+		funcs[name] = fn
+	}
+
+	funcs["resize"] = rd.resizeImage
+	funcs["srcset"] = rd.imageSrcset
+	funcs["asset"] = rd.getAsset
+	return funcs
+}
+
+// resizeImage returns URL of the image variant with the specified width. If image
+// processing is not available or the image can't be resized, the URL is returned as it is.
+func (rd Renderer) resizeImage(imageURL string, width int) (string, error) {
+	if rd.Images == nil || imageURL == "" || !imaging.IsSupported(imageURL) {
+		return imageURL, nil
+	}
+
+	variant, err := rd.Images.Resize(fp.ToSlash(imageURL), width)
+	if err != nil {
+		return "", err
+	}
+
+	return variant.URL, nil
+}
+
+// imageSrcset returns the srcset for image, e.g. thumbnail of post, using the
+// thumbnail widths in image config.
+func (rd Renderer) imageSrcset(imageURL string) (template.Srcset, error) {
+	widths := rd.Config.Image.ThumbnailWidths
+	if rd.Images == nil || imageURL == "" || !imaging.IsSupported(imageURL) || len(widths) == 0 {
+		return template.Srcset(imageURL), nil
+	}
+
+	srcset, _, _, err := rd.Images.Srcset(fp.ToSlash(imageURL), widths)
+	if err != nil {
+		return "", err
+	}
+
+	return template.Srcset(srcset), nil
+}
+
+// getAsset returns the processed asset file in theme, e.g. CSS or JS, so theme can
+// use its fingerprinted URL and integrity hash. If the assets are not processed,
+// the asset is returned with URL from its name and without integrity hash.
+func (rd Renderer) getAsset(name string) (asset.Asset, error) {
+	name = strings.TrimPrefix(name, "/")
+	if rd.Assets == nil {
+		return asset.Asset{Name: name, URL: "/" + name}, nil
+	}
+
+	result, exist := rd.Assets.Get(name)
+	if !exist {
+		return asset.Asset{}, fmt.Errorf("asset %s is not found", name)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package renderer
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-spook/spook/model"
+	sanitized "github.com/shurcooL/sanitized_anchor_name"
+)
+
+const (
+	// IDStrategyGithub creates heading ID like Github, which keeps letters and numbers
+	// from any language but drops the combining marks. This is the default strategy.
+	IDStrategyGithub = "github"
+	// IDStrategyUnicode creates heading ID that keeps letters, numbers and combining
+	// marks from any language, which needed by scripts like Devanagari or Thai.
+	IDStrategyUnicode = "unicode"
+	// IDStrategyASCII creates heading ID that only contains ASCII letters and numbers.
+	IDStrategyASCII = "ascii"
+)
+
+// processHeadings creates unique ID for every heading in generated HTML that doesn't
+// have explicit ID or whose explicit ID is already used, then adds anchor link to the
+// headings if it's enabled in config.
+func processHeadings(doc *goquery.Document, ctx *TransformContext) error {
+	config := ctx.Config.Markup.Heading
+	switch config.IDStrategy {
+	case "", IDStrategyGithub, IDStrategyUnicode, IDStrategyASCII:
+	default:
+		return fmt.Errorf("unknown heading ID strategy: %s", config.IDStrategy)
+	}
+
+	// Reserve IDs that used by other elements, e.g. footnotes, since they are
+	// linked by the markdown engine and can't be changed. After that, reserve the
+	// explicit ID like "## Heading {#id}" for the first heading that uses it. Since
+	// the markdown engines don't generate heading ID, the existing ID on heading is
+	// always explicit. Every heading is identified by its index in the document.
+	headingSelector := "h1,h2,h3,h4,h5,h6"
+	usedIDs := map[string]int{}
+	doc.Find("[id]").Not(headingSelector).Each(func(_ int, s *goquery.Selection) {
+		usedIDs[s.AttrOr("id", "")] = -1
+	})
+
+	headings := doc.Find(headingSelector)
+	headings.Each(func(i int, s *goquery.Selection) {
+		id := s.AttrOr("id", "")
+		if _, used := usedIDs[id]; id != "" && !used {
+			usedIDs[id] = i
+		}
+	})
+
+	headings.Each(func(i int, s *goquery.Selection) {
+		// Keep the explicit ID if it's reserved for this heading. Else, this heading
+		// doesn't have ID or its explicit ID is already used, so make it unique.
+		uniqueID := s.AttrOr("id", "")
+		if owner, used := usedIDs[uniqueID]; uniqueID == "" || !used || owner != i {
+			id := uniqueID
+			if id == "" {
+				id = createHeadingID(s.Text(), config.IDStrategy)
+			}
+			if id == "" {
+				id = "heading"
+			}
+
+			uniqueID = id
+			for n := 1; ; n++ {
+				if _, used := usedIDs[uniqueID]; !used {
+					break
+				}
+				uniqueID = fmt.Sprintf("%s-%d", id, n)
+			}
+
+			usedIDs[uniqueID] = i
+			s.SetAttr("id", uniqueID)
+		}
+
+		// Add anchor link
+		if config.Anchor && isAnchorLevel(config, goquery.NodeName(s)) {
+			symbol := config.AnchorSymbol
+			if symbol == "" {
+				symbol = "#"
+			}
+
+			anchor := fmt.Sprintf(`<a class="anchor" href="#%s" aria-hidden="true">%s</a>`,
+				html.EscapeString(uniqueID), html.EscapeString(symbol))
+
+			if config.AnchorPosition == "before" {
+				s.PrependHtml(anchor)
+			} else {
+				s.AppendHtml(anchor)
+			}
+		}
+	})
+
+	return nil
+}
+
+// isAnchorLevel checks whether heading with specified tag name should
+// have anchor link. If levels is not specified, all headings will have it.
+func isAnchorLevel(config model.HeadingConfig, tagName string) bool {
+	if len(config.AnchorLevels) == 0 {
+		return true
+	}
+
+	for _, level := range config.AnchorLevels {
+		if tagName == fmt.Sprintf("h%d", level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// createHeadingID creates ID for heading with specified text.
+func createHeadingID(text string, strategy string) string {
+	var isAllowed func(r rune) bool
+	switch strategy {
+	case IDStrategyUnicode:
+		isAllowed = func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+		}
+	case IDStrategyASCII:
+		isAllowed = func(r rune) bool {
+			return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsNumber(r))
+		}
+	default:
+		return sanitized.Create(text)
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !isAllowed(r)
+	})
+
+	return strings.ToLower(strings.Join(words, "-"))
+}
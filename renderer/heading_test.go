@@ -0,0 +1,66 @@
+package renderer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestProcessHeadingsUniqueID makes sure every heading has unique ID, including
+// the headings whose explicit ID is used by another heading or by footnote.
+func TestProcessHeadingsUniqueID(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected []string
+	}{{
+		name:     "generated",
+		html:     `<h2>Intro</h2><h2>Intro</h2><h3>Intro</h3>`,
+		expected: []string{"intro", "intro-1", "intro-2"},
+	}, {
+		name:     "explicit",
+		html:     `<h2 id="a">One</h2><h2 id="b">Two</h2>`,
+		expected: []string{"a", "b"},
+	}, {
+		name:     "duplicate explicit",
+		html:     `<h2 id="a">One</h2><h2 id="a">Two</h2><h2 id="a">Three</h2>`,
+		expected: []string{"a", "a-1", "a-2"},
+	}, {
+		name:     "explicit after generated",
+		html:     `<h2>Intro</h2><h2 id="intro">Other</h2>`,
+		expected: []string{"intro-1", "intro"},
+	}, {
+		name:     "explicit clashes with footnote",
+		html:     `<h2 id="fn:1">Note</h2><p>Text</p><ol><li id="fn:1">Footnote</li></ol>`,
+		expected: []string{"fn:1-1"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = processHeadings(doc, &TransformContext{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []string{}
+			doc.Find("h1,h2,h3,h4,h5,h6").Each(func(_ int, s *goquery.Selection) {
+				ids = append(ids, s.AttrOr("id", ""))
+			})
+
+			if !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("got heading IDs %v, want %v", ids, test.expected)
+			}
+
+			if fnID := doc.Find("li").AttrOr("id", ""); strings.Contains(test.html, "<li") && fnID != "fn:1" {
+				t.Errorf("footnote ID changed to %q", fnID)
+			}
+		})
+	}
+}
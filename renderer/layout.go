@@ -3,6 +3,7 @@ package renderer
 import (
 	"html/template"
 
+	"github.com/go-spook/spook/imaging"
 	"github.com/go-spook/spook/model"
 )
 
@@ -14,6 +15,7 @@ type Layout struct {
 	ContentDesc   string
 	ContentAuthor string
 	Pages         []model.Page
+	Params        map[string]interface{}
 }
 
 // List is layout that used in list template.
@@ -34,6 +36,10 @@ type Page struct {
 	Layout
 	Thumbnail string
 	HTML      template.HTML
+	TOC       template.HTML
+	HasMath   bool
+	Backlinks []Backlink
+	Resources []Resource
 }
 
 // Post is layout that used in post
@@ -45,6 +51,61 @@ type Post struct {
 	Tags      []model.Group
 	Thumbnail string
 	HTML      template.HTML
+	TOC       template.HTML
+	HasMath   bool
+	Backlinks []Backlink
+	Resources []Resource
 	Older     model.Post
 	Newer     model.Post
 }
+
+// Backlink is a post or page that refers to the current
+// content using wiki link, e.g. [[post-slug]].
+type Backlink struct {
+	Title   string
+	Excerpt string
+	Path    string
+	IsPost  bool
+}
+
+// Resource is a file inside the directory of post or page, e.g. image or attachment.
+// For image, its dimension and EXIF metadata (if any) are included as well.
+type Resource struct {
+	Name     string
+	URL      string
+	MIMEType string
+	Size     int64
+	IsImage  bool
+	Width    int
+	Height   int
+	EXIF     *imaging.EXIF
+}
+
+// Gallery is data that used in shortcode-gallery.html template,
+// which called for every gallery shortcode in post and page.
+type Gallery struct {
+	Images   []Resource
+	PagePath string
+}
+
+// Link is data that used in render-link.html hook, which called
+// for every link inside the content of post and page.
+type Link struct {
+	Destination string
+	URL         string
+	Title       string
+	Text        template.HTML
+	PlainText   string
+	IsExternal  bool
+	PagePath    string
+}
+
+// Image is data that used in render-image.html hook, which called
+// for every image inside the content of post and page.
+type Image struct {
+	Destination string
+	URL         string
+	Title       string
+	Alt         string
+	PagePath    string
+}
@@ -0,0 +1,127 @@
+package renderer
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/url"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/go-spook/spook/markup"
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/theme"
+)
+
+// ConvertMarkdown converts markdown content of post or page in pagePath into HTML using
+// the engine that specified in config, then transforms it using the enabled transformers.
+// The hook templates in theme chain are used to render links and images in the content.
+// It's used as converter for parser, so each content is only converted once.
+func (rd Renderer) ConvertMarkdown(pagePath string, content []byte) (model.Content, error) {
+	chain, err := theme.Chain(rd.FS, rd.Config.Theme)
+	if err != nil {
+		return model.Content{}, err
+	}
+
+	engine, err := markup.New(rd.Config.Markup)
+	if err != nil {
+		return model.Content{}, err
+	}
+
+	opts := markup.Options{
+		WikiLinkResolver: rd.resolveWikiLink,
+		Shortcodes:       rd.getShortcodes(chain, pagePath),
+	}
+	if tplLink := findTemplate(chain, "render-link.html"); tplLink != nil {
+		tpl, err := rd.parseTemplates(tplLink)
+		if err != nil {
+			return model.Content{}, err
+		}
+
+		opts.LinkHook = func(w io.Writer, link markup.Link) error {
+			resolvedURL, isExternal := resolveURL(link.Destination, pagePath)
+			return executeHook(w, tpl, "render-link.html", &Link{
+				Destination: link.Destination,
+				URL:         resolvedURL,
+				Title:       link.Title,
+				Text:        template.HTML(link.Text),
+				PlainText:   link.PlainText,
+				IsExternal:  isExternal,
+				PagePath:    pagePath,
+			})
+		}
+	}
+
+	if tplImage := findTemplate(chain, "render-image.html"); tplImage != nil {
+		tpl, err := rd.parseTemplates(tplImage)
+		if err != nil {
+			return model.Content{}, err
+		}
+
+		opts.ImageHook = func(w io.Writer, image markup.Image) error {
+			resolvedURL, _ := resolveURL(image.Destination, pagePath)
+			return executeHook(w, tpl, "render-image.html", &Image{
+				Destination: image.Destination,
+				URL:         resolvedURL,
+				Title:       image.Title,
+				Alt:         image.Alt,
+				PagePath:    pagePath,
+			})
+		}
+	}
+
+	result, err := markup.Convert(engine, content, opts)
+	if err != nil {
+		return model.Content{}, err
+	}
+
+	ctx := TransformContext{
+		Config:   rd.Config,
+		PagePath: pagePath,
+		Images:   rd.Images,
+	}
+
+	output, err := rd.transformHTML(result.HTML, &ctx)
+	if err != nil {
+		return model.Content{}, err
+	}
+
+	return model.Content{
+		HTML:    string(output),
+		TOC:     string(ctx.TOC),
+		HasMath: result.HasMath,
+	}, nil
+}
+
+// executeHook executes hook template and writes the trimmed result into w.
+func executeHook(w io.Writer, tpl *template.Template, name string, data interface{}) error {
+	buffer := bytes.Buffer{}
+	if err := tpl.ExecuteTemplate(&buffer, name, data); err != nil {
+		return err
+	}
+
+	_, err := w.Write(bytes.TrimSpace(buffer.Bytes()))
+	return err
+}
+
+// resolveURL resolves the relative destination of link or image against the page path.
+// Returns the resolved URL and whether the destination is external or not.
+func resolveURL(destination string, pagePath string) (string, bool) {
+	dstURL, err := url.Parse(destination)
+	if err != nil {
+		return destination, false
+	}
+
+	if dstURL.IsAbs() || dstURL.Host != "" {
+		isExternal := dstURL.Scheme == "http" || dstURL.Scheme == "https" || dstURL.Scheme == ""
+		return destination, isExternal
+	}
+
+	if destination == "" || strings.HasPrefix(destination, "/") || strings.HasPrefix(destination, "#") {
+		return destination, false
+	}
+
+	pagePath = "/" + strings.Trim(fp.ToSlash(pagePath), "/") + "/"
+	baseURL := &url.URL{Path: pagePath}
+	return baseURL.ResolveReference(dstURL).String(), false
+}
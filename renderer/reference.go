@@ -0,0 +1,185 @@
+package renderer
+
+import (
+	"fmt"
+	"path"
+	fp "path/filepath"
+	"sort"
+	"strings"
+
+	sanitized "github.com/shurcooL/sanitized_anchor_name"
+)
+
+// resolveWikiLink finds the post or page that referenced by wiki link, e.g. [[post-slug]].
+// The target is either the full path of post or page, e.g. [[page/about]], which is useful
+// when a post and a page share the same name, the Slug in its metadata, the name of its
+// directory or its title converted into slug, in that order of priority. Since the slug
+// and title are kept when the directory is renamed, it's better to refer to them.
+// If the target is not found, the error lists the closest names as suggestion.
+func (rd Renderer) resolveWikiLink(target string) (string, string, error) {
+	target = strings.Trim(target, "/")
+
+	bestPath, bestTitle, bestMatch := "", "", noMatch
+	checkMatch := func(contentPath, slug, title string) {
+		if match := matchReference(target, contentPath, slug, title); match < bestMatch {
+			bestPath, bestTitle, bestMatch = fp.ToSlash(contentPath), title, match
+		}
+	}
+
+	for _, post := range rd.Posts {
+		checkMatch(post.Path, post.Slug, post.Title)
+	}
+
+	for _, page := range rd.Pages {
+		checkMatch(page.Path, page.Slug, page.Title)
+	}
+
+	if bestMatch != noMatch {
+		return bestPath, bestTitle, nil
+	}
+
+	err := fmt.Errorf("broken reference [[%s]]: post or page is not exist", target)
+	if candidates := rd.closestReferences(target); len(candidates) > 0 {
+		err = fmt.Errorf("%v, did you mean %s?", err, strings.Join(candidates, ", "))
+	}
+
+	return "", "", err
+}
+
+// getBacklinks returns list of posts and pages that refer to the content in contentPath.
+func (rd Renderer) getBacklinks(contentPath string) []Backlink {
+	contentPath = trimContentPath(contentPath)
+	hasReference := func(references []string) bool {
+		for _, target := range references {
+			targetPath, _, err := rd.resolveWikiLink(target)
+			if err == nil && trimContentPath(targetPath) == contentPath {
+				return true
+			}
+		}
+		return false
+	}
+
+	backlinks := []Backlink{}
+	for _, post := range rd.Posts {
+		if trimContentPath(post.Path) != contentPath && hasReference(post.References) {
+			backlinks = append(backlinks, Backlink{
+				Title:   post.Title,
+				Excerpt: post.Excerpt,
+				Path:    post.Path,
+				IsPost:  true,
+			})
+		}
+	}
+
+	for _, page := range rd.Pages {
+		if trimContentPath(page.Path) != contentPath && hasReference(page.References) {
+			backlinks = append(backlinks, Backlink{
+				Title:   page.Title,
+				Excerpt: page.Excerpt,
+				Path:    page.Path,
+			})
+		}
+	}
+
+	return backlinks
+}
+
+// referenceMatch is how the target of wiki link matches a content. The lower is better.
+type referenceMatch int
+
+const (
+	matchPath referenceMatch = iota
+	matchSlug
+	matchDir
+	matchTitle
+	noMatch
+)
+
+// matchReference checks how the target of wiki link matches the content in
+// contentPath, which slug and title are taken from its metadata.
+func matchReference(target string, contentPath string, slug string, title string) referenceMatch {
+	contentPath = trimContentPath(contentPath)
+	switch {
+	case strings.Contains(target, "/"):
+		if target == contentPath {
+			return matchPath
+		}
+	case slug != "" && target == slug:
+		return matchSlug
+	case target == path.Base(contentPath):
+		return matchDir
+	case title != "" && target == sanitized.Create(title):
+		return matchTitle
+	}
+
+	return noMatch
+}
+
+// trimContentPath converts path of post or page into slash separated
+// path without leading slash, e.g. "post/hello", so they can be compared.
+func trimContentPath(contentPath string) string {
+	return strings.Trim(fp.ToSlash(contentPath), "/")
+}
+
+// closestReferences returns at most three slugs and directory names of posts and pages
+// which are the most similar with the target of wiki link, sorted by their similarity.
+func (rd Renderer) closestReferences(target string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	candidates := []candidate{}
+	visited := map[string]struct{}{}
+	addCandidate := func(name string) {
+		if _, exist := visited[name]; exist || name == "" {
+			return
+		}
+		visited[name] = struct{}{}
+
+		// Only suggest the names that differ in at most a quarter of their characters
+		distance := levenshtein(target, name)
+		if distance <= (len(name)+3)/4 {
+			candidates = append(candidates, candidate{name: name, distance: distance})
+		}
+	}
+
+	for _, post := range rd.Posts {
+		addCandidate(post.Slug)
+		addCandidate(path.Base(fp.ToSlash(post.Path)))
+	}
+
+	for _, page := range rd.Pages {
+		addCandidate(page.Slug)
+		addCandidate(path.Base(fp.ToSlash(page.Path)))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	names := []string{}
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		names = append(names, "[["+candidates[i].name+"]]")
+	}
+
+	return names
+}
+
+// LinkedContents returns path of posts and pages that linked with the content in
+// contentPath, i.e. the contents that referred by it and the contents that refer to it.
+// Their metadata is used when rendering the content, e.g. as title of wiki links.
+func (rd Renderer) LinkedContents(contentPath string, references []string) []string {
+	paths := []string{}
+	for _, target := range references {
+		if targetPath, _, err := rd.resolveWikiLink(target); err == nil {
+			paths = append(paths, targetPath)
+		}
+	}
+
+	for _, backlink := range rd.getBacklinks(contentPath) {
+		paths = append(paths, fp.ToSlash(backlink.Path))
+	}
+
+	return paths
+}
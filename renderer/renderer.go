@@ -5,17 +5,20 @@ import (
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"math"
 	"path"
 	fp "path/filepath"
 	"sort"
 	"strings"
 
+	fhtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/go-spook/spook/asset"
+	"github.com/go-spook/spook/imaging"
 	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/theme"
 	"github.com/tdewolff/minify"
 	"github.com/tdewolff/minify/html"
-	bf "gopkg.in/russross/blackfriday.v2"
 )
 
 // ListType is the type of list that will be rendered.
@@ -28,11 +31,12 @@ const (
 	CATEGORY
 	// TAG means the list is list that only shows posts with specified tags.
 	TAG
-
-	mdExtensions = bf.CommonExtensions | bf.Footnotes | bf.AutoHeadingIDs | bf.HeadingIDs
 )
 
-// Renderer is used to render static HTML file
+// Renderer is used to render static HTML file. The themes and the files of contents
+// are read from FS, which is the file system of the site root dir. Renderer is safe for
+// concurrent use, since its fields are never modified while rendering and the image
+// processor is synchronized.
 type Renderer struct {
 	Config     model.Config
 	Pages      []model.Page
@@ -40,7 +44,9 @@ type Renderer struct {
 	Tags       []model.Group
 	Categories []model.Group
 	Minimize   bool
-	RootDir    string
+	FS         fs.FS
+	Images     *imaging.Processor
+	Assets     *asset.Manifest
 }
 
 var funcsMap = template.FuncMap{
@@ -60,20 +66,20 @@ func (rd Renderer) RenderFrontPage(dst io.Writer) error {
 	}
 
 	// Prepare templates
-	themeDir := fp.Join(rd.RootDir, "theme", rd.Config.Theme)
-	tplList := fp.Join(themeDir, "list.html")
-	tplFrontPage := fp.Join(themeDir, "frontpage.html")
-
-	templates, err := rd.getBaseTemplates()
+	chain, err := theme.Chain(rd.FS, rd.Config.Theme)
 	if err != nil {
 		return err
 	}
 
+	tplList := findTemplate(chain, "list.html")
+	tplFrontPage := findTemplate(chain, "frontpage.html")
+	templates := getBaseTemplates(chain)
+
 	activeTemplate := ""
-	if fileExists(tplFrontPage) {
+	if tplFrontPage != nil {
 		activeTemplate = "frontpage.html"
 		templates = append(templates, tplFrontPage)
-	} else if fileExists(tplList) {
+	} else if tplList != nil {
 		activeTemplate = "list.html"
 		templates = append(templates, tplList)
 	} else {
@@ -88,6 +94,7 @@ func (rd Renderer) RenderFrontPage(dst io.Writer) error {
 		ContentDesc:   rd.Config.Description,
 		ContentAuthor: rd.Config.Owner,
 		Pages:         rd.Pages,
+		Params:        theme.Params(chain, rd.Config),
 	}
 
 	frontPage := List{
@@ -101,7 +108,7 @@ func (rd Renderer) RenderFrontPage(dst io.Writer) error {
 	}
 
 	// Execute templates
-	tpl, err := template.New("").Funcs(funcsMap).ParseFiles(templates...)
+	tpl, err := rd.parseTemplates(templates...)
 	if err != nil {
 		return err
 	}
@@ -118,17 +125,17 @@ func (rd Renderer) RenderList(listType ListType, groupName string, pageNumber in
 	}
 
 	// Prepare templates
-	themeDir := fp.Join(rd.RootDir, "theme", rd.Config.Theme)
-	tplList := fp.Join(themeDir, "list.html")
-	if !fileExists(tplList) {
-		return -1, fmt.Errorf("Template for list is not exist")
-	}
-
-	templates, err := rd.getBaseTemplates()
+	chain, err := theme.Chain(rd.FS, rd.Config.Theme)
 	if err != nil {
 		return -1, err
 	}
 
+	tplList := findTemplate(chain, "list.html")
+	if tplList == nil {
+		return -1, fmt.Errorf("Template for list is not exist")
+	}
+
+	templates := getBaseTemplates(chain)
 	templates = append(templates, tplList)
 
 	// Filter posts by group
@@ -182,6 +189,7 @@ func (rd Renderer) RenderList(listType ListType, groupName string, pageNumber in
 		ContentTitle: groupName,
 		ContentDesc:  rd.Config.Description,
 		Pages:        rd.Pages,
+		Params:       theme.Params(chain, rd.Config),
 	}
 
 	listPath := "/posts"
@@ -201,7 +209,7 @@ func (rd Renderer) RenderList(listType ListType, groupName string, pageNumber in
 	}
 
 	// Execute templates
-	tpl, err := template.New("").Funcs(funcsMap).ParseFiles(templates...)
+	tpl, err := rd.parseTemplates(templates...)
 	if err != nil {
 		return -1, err
 	}
@@ -223,29 +231,29 @@ func (rd Renderer) RenderPage(page model.Page, dst io.Writer) error {
 	}
 
 	// Prepare templates
-	themeDir := fp.Join(rd.RootDir, "theme", rd.Config.Theme)
-	tplPage := fp.Join(themeDir, "page.html")
-	if !fileExists(tplPage) {
-		return fmt.Errorf("Template for page is not exist")
-	}
-
-	templates, err := rd.getBaseTemplates()
+	chain, err := theme.Chain(rd.FS, rd.Config.Theme)
 	if err != nil {
 		return err
 	}
 
+	tplPage := findTemplate(chain, "page.html")
+	if tplPage == nil {
+		return fmt.Errorf("Template for page is not exist")
+	}
+
+	templates := getBaseTemplates(chain)
 	templates = append(templates, tplPage)
 
-	// Open index file
-	content, err := readIndexFile(page.Path)
+	// Make sure the content has been parsed
+	if page.Content == nil {
+		return fmt.Errorf("content of %s is not parsed", page.Path)
+	}
+
+	resources, err := rd.getResources(page.Path)
 	if err != nil {
 		return err
 	}
 
-	content = removeMetadata(content)
-	html := bf.Run(content, bf.WithExtensions(mdExtensions))
-	html = highlightCode(html)
-
 	// Prepare layout
 	baseLayout := Layout{
 		WebsiteTitle: rd.Config.Title,
@@ -253,16 +261,21 @@ func (rd Renderer) RenderPage(page model.Page, dst io.Writer) error {
 		ContentTitle: page.Title,
 		ContentDesc:  page.Excerpt,
 		Pages:        rd.Pages,
+		Params:       theme.Params(chain, rd.Config),
 	}
 
 	pageLayout := Page{
 		Layout:    baseLayout,
 		Thumbnail: page.Thumbnail,
-		HTML:      template.HTML(html),
+		HTML:      template.HTML(page.Content.HTML),
+		TOC:       template.HTML(page.Content.TOC),
+		HasMath:   page.Content.HasMath,
+		Backlinks: rd.getBacklinks(page.Path),
+		Resources: resources,
 	}
 
 	// Execute templates
-	tpl, err := template.New("").Funcs(funcsMap).ParseFiles(templates...)
+	tpl, err := rd.parseTemplates(templates...)
 	if err != nil {
 		return err
 	}
@@ -279,17 +292,17 @@ func (rd Renderer) RenderPost(post, olderPost, newerPost model.Post, dst io.Writ
 	}
 
 	// Prepare templates
-	themeDir := fp.Join(rd.RootDir, "theme", rd.Config.Theme)
-	tplPost := fp.Join(themeDir, "post.html")
-	if !fileExists(tplPost) {
-		return fmt.Errorf("Template for post is not exist")
-	}
-
-	templates, err := rd.getBaseTemplates()
+	chain, err := theme.Chain(rd.FS, rd.Config.Theme)
 	if err != nil {
 		return err
 	}
 
+	tplPost := findTemplate(chain, "post.html")
+	if tplPost == nil {
+		return fmt.Errorf("Template for post is not exist")
+	}
+
+	templates := getBaseTemplates(chain)
 	templates = append(templates, tplPost)
 
 	// Convert category and tags of post into Group
@@ -320,16 +333,16 @@ func (rd Renderer) RenderPost(post, olderPost, newerPost model.Post, dst io.Writ
 		post.Author = rd.Config.Owner
 	}
 
-	// Open index file
-	content, err := readIndexFile(post.Path)
+	// Make sure the content has been parsed
+	if post.Content == nil {
+		return fmt.Errorf("content of %s is not parsed", post.Path)
+	}
+
+	resources, err := rd.getResources(post.Path)
 	if err != nil {
 		return err
 	}
 
-	content = removeMetadata(content)
-	html := bf.Run(content, bf.WithExtensions(mdExtensions))
-	html = highlightCode(html)
-
 	// Prepare layout
 	baseLayout := Layout{
 		WebsiteTitle:  rd.Config.Title,
@@ -338,6 +351,7 @@ func (rd Renderer) RenderPost(post, olderPost, newerPost model.Post, dst io.Writ
 		ContentDesc:   post.Excerpt,
 		ContentAuthor: post.Author,
 		Pages:         rd.Pages,
+		Params:        theme.Params(chain, rd.Config),
 	}
 
 	postLayout := Post{
@@ -347,13 +361,17 @@ func (rd Renderer) RenderPost(post, olderPost, newerPost model.Post, dst io.Writ
 		Category:  category,
 		Tags:      tags,
 		Thumbnail: post.Thumbnail,
-		HTML:      template.HTML(html),
+		HTML:      template.HTML(post.Content.HTML),
+		TOC:       template.HTML(post.Content.TOC),
+		HasMath:   post.Content.HasMath,
+		Backlinks: rd.getBacklinks(post.Path),
+		Resources: resources,
 		Older:     olderPost,
 		Newer:     newerPost,
 	}
 
 	// Execute templates
-	tpl, err := template.New("").Funcs(funcsMap).ParseFiles(templates...)
+	tpl, err := rd.parseTemplates(templates...)
 	if err != nil {
 		return err
 	}
@@ -361,6 +379,57 @@ func (rd Renderer) RenderPost(post, olderPost, newerPost model.Post, dst io.Writ
 	return rd.executeTemplate(tpl, dst, "post.html", &postLayout)
 }
 
+// RenderTemplate renders template with the name that not tied to any content, e.g. 404.html,
+// using the layout of the site as its data.
+func (rd Renderer) RenderTemplate(name string, dst io.Writer) error {
+	// Make sure config file is valid
+	err := rd.validateConfig()
+	if err != nil {
+		return err
+	}
+
+	// Prepare templates
+	chain, err := theme.Chain(rd.FS, rd.Config.Theme)
+	if err != nil {
+		return err
+	}
+
+	tplActive := findTemplate(chain, name)
+	if tplActive == nil {
+		return fmt.Errorf("Template %s is not exist", name)
+	}
+
+	templates := getBaseTemplates(chain)
+	templates = append(templates, tplActive)
+
+	// Prepare layout
+	baseLayout := Layout{
+		WebsiteTitle:  rd.Config.Title,
+		WebsiteOwner:  rd.Config.Owner,
+		ContentTitle:  rd.Config.Title,
+		ContentDesc:   rd.Config.Description,
+		ContentAuthor: rd.Config.Owner,
+		Pages:         rd.Pages,
+		Params:        theme.Params(chain, rd.Config),
+	}
+
+	// Execute templates
+	tpl, err := rd.parseTemplates(templates...)
+	if err != nil {
+		return err
+	}
+
+	return rd.executeTemplate(tpl, dst, name, &baseLayout)
+}
+
+// WriteHighlightCSS writes the stylesheet for highlighted code using
+// the style and options that specified in highlight config.
+func WriteHighlightCSS(w io.Writer, config model.HighlightConfig) error {
+	config.InlineStyle = false
+	formatter := fhtml.New(highlightOptions(config)...)
+	return formatter.WriteCSS(w, highlightStyle(config))
+}
+
 // validateConfig verifies that the config file is valid.
 func (rd Renderer) validateConfig() error {
 	if rd.Config.Theme == "" {
@@ -370,28 +439,82 @@ func (rd Renderer) validateConfig() error {
 	return nil
 }
 
-// getBaseTemplates fetch list of base templates that used in the theme.
+// templateFile is a template file inside the file system of a theme.
+type templateFile struct {
+	FS   fs.FS
+	Name string
+}
+
+// getBaseTemplates fetch list of base templates that used in the theme chain.
 // The base template is all HTML that prefixed with underscore character,
-// e.g _footer.html, _header.html, etc.
-func (rd Renderer) getBaseTemplates() ([]string, error) {
-	themeDir := fp.Join(rd.RootDir, "theme", rd.Config.Theme)
-	items, err := ioutil.ReadDir(themeDir)
-	if err != nil {
-		return []string{}, err
+// e.g _footer.html, _header.html, etc. The base template in child theme
+// overrides the base template with the same name in its parent.
+func getBaseTemplates(chain []model.Theme) []*templateFile {
+	templates := []*templateFile{}
+	visited := map[string]struct{}{}
+
+	for _, th := range chain {
+		items, err := fs.ReadDir(th.FS, ".")
+		if err != nil {
+			continue
+		}
+
+		for _, item := range items {
+			if item.IsDir() {
+				continue
+			}
+
+			name := item.Name()
+			if !strings.HasSuffix(name, ".html") || !strings.HasPrefix(name, "_") {
+				continue
+			}
+
+			if _, exist := visited[name]; exist {
+				continue
+			}
+
+			visited[name] = struct{}{}
+			templates = append(templates, &templateFile{FS: th.FS, Name: name})
+		}
 	}
 
-	templates := []string{}
-	for _, item := range items {
-		if item.IsDir() {
-			continue
+	// Since template with the same name will be replaced by the one that parsed later,
+	// make sure the templates from parent parsed first.
+	for i, j := 0, len(templates)-1; i < j; i, j = i+1, j-1 {
+		templates[i], templates[j] = templates[j], templates[i]
+	}
+
+	return templates
+}
+
+// findTemplate looks for template with specified name in the theme chain.
+// Returns nil if the template is not found or empty.
+func findTemplate(chain []model.Theme, name string) *templateFile {
+	th, found := theme.FindFile(chain, name)
+	if !found {
+		return nil
+	}
+
+	return &templateFile{FS: th.FS, Name: name}
+}
+
+// parseTemplates parses the template files like template.ParseFiles,
+// except the files are read from the file system of their theme.
+func (rd Renderer) parseTemplates(files ...*templateFile) (*template.Template, error) {
+	tpl := template.New("").Funcs(rd.funcsMap())
+	for _, file := range files {
+		content, err := fs.ReadFile(file.FS, file.Name)
+		if err != nil {
+			return nil, err
 		}
 
-		if strings.HasSuffix(item.Name(), ".html") && strings.HasPrefix(item.Name(), "_") {
-			templates = append(templates, fp.Join(themeDir, item.Name()))
+		_, err = tpl.New(path.Base(file.Name)).Parse(string(content))
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return templates, nil
+	return tpl, nil
 }
 
 // getMaxPagination calculates the max page number following the configuration.
@@ -0,0 +1,152 @@
+package renderer
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	fp "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-spook/spook/imaging"
+)
+
+// resourceTypes is the MIME type of common resource files, keyed by their extension.
+// It's used instead of the MIME database of the system, so the detected type is the
+// same on every machine.
+var resourceTypes = map[string]string{
+	".avif": "image/avif",
+	".bmp":  "image/bmp",
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".css":  "text/css",
+	".csv":  "text/csv",
+	".htm":  "text/html",
+	".html": "text/html",
+	".md":   "text/markdown",
+	".txt":  "text/plain",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".pdf":  "application/pdf",
+	".xml":  "application/xml",
+	".zip":  "application/zip",
+}
+
+// getResources returns list of files inside the directory of post or page, except
+// the index file and hidden files. The files in sub directory are included as well,
+// with their name relative to the content directory, e.g. "photos/beach.jpg".
+func (rd Renderer) getResources(contentPath string) ([]Resource, error) {
+	contentDir := strings.Trim(fp.ToSlash(contentPath), "/")
+	contentURL := "/" + contentDir
+
+	resources := []Resource{}
+	err := fs.WalkDir(rd.FS, contentDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := entry.Name()
+		if filePath != contentDir && strings.HasPrefix(name, ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() || filePath == path.Join(contentDir, "_index.md") {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		relPath := strings.TrimPrefix(filePath, contentDir+"/")
+		resource := Resource{
+			Name:     relPath,
+			URL:      path.Join(contentURL, relPath),
+			MIMEType: rd.detectMIMEType(filePath),
+			Size:     info.Size(),
+		}
+
+		if strings.HasPrefix(resource.MIMEType, "image/") {
+			resource.IsImage = true
+			rd.readImageInfo(filePath, &resource)
+		}
+
+		resources = append(resources, resource)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+
+	return resources, nil
+}
+
+// readImageInfo reads the dimension and EXIF metadata of image resource. If possible,
+// they are read using the image processor, which caches them between renders.
+// The image that can't be decoded is left as it is, since it's not fatal.
+func (rd Renderer) readImageInfo(filePath string, resource *Resource) {
+	var exif *imaging.EXIF
+	if rd.Images != nil {
+		info, err := rd.Images.Info(filePath)
+		if err != nil {
+			return
+		}
+
+		resource.Width, resource.Height, exif = info.Width, info.Height, info.EXIF
+	} else {
+		resource.Width, resource.Height, _ = imaging.Size(rd.FS, filePath)
+		if resource.MIMEType == "image/jpeg" {
+			exif, _ = imaging.ReadEXIF(rd.FS, filePath)
+		}
+	}
+
+	if resource.MIMEType != "image/jpeg" || exif == nil {
+		return
+	}
+
+	// Copy the EXIF, since the cached one is shared between renders
+	exifCopy := *exif
+	if !rd.Config.Image.ExposeGPS {
+		exifCopy.HasGPS = false
+		exifCopy.Latitude = 0
+		exifCopy.Longitude = 0
+	}
+
+	resource.EXIF = &exifCopy
+}
+
+// detectMIMEType detects the MIME type of file, from its extension
+// or from its content if the extension is not recognized.
+func (rd Renderer) detectMIMEType(filePath string) string {
+	if mimeType, ok := resourceTypes[strings.ToLower(path.Ext(filePath))]; ok {
+		return mimeType
+	}
+
+	f, err := rd.FS.Open(filePath)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	buffer := make([]byte, 512)
+	n, _ := f.Read(buffer)
+	return strings.SplitN(http.DetectContentType(buffer[:n]), ";", 2)[0]
+}
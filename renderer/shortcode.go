@@ -0,0 +1,257 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	fp "path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/go-spook/spook/markup"
+	"github.com/go-spook/spook/model"
+)
+
+var (
+	rxRegionStart = regexp.MustCompile(`#region\b\s*(\S*)`)
+	rxRegionEnd   = regexp.MustCompile(`#endregion\b\s*(\S*)`)
+)
+
+// getShortcodes returns the shortcodes that can be used in content of post or page.
+func (rd Renderer) getShortcodes(chain []model.Theme, pagePath string) map[string]markup.Shortcode {
+	bundleDir := strings.Trim(fp.ToSlash(pagePath), "/")
+
+	return map[string]markup.Shortcode{
+		"include": func(args map[string]string) (string, error) {
+			return includeFile(rd.FS, bundleDir, args)
+		},
+		"gallery": func(args map[string]string) (string, error) {
+			return rd.renderGallery(chain, pagePath, args)
+		},
+	}
+}
+
+// includeFile returns the content of a file inside the bundle dir as fenced code block,
+// e.g. {{< include "main.go" lines="3-10" >}} or {{< include "main.go" region="setup" >}}.
+// The language is detected from the file extension unless specified using "lang" arg.
+// If "raw" arg is true, the content is included as it is, i.e. as markdown.
+func includeFile(fsys fs.FS, bundleDir string, args map[string]string) (string, error) {
+	name := args["file"]
+	if name == "" {
+		name = args["0"]
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("file is not specified")
+	}
+
+	// Make sure the file is inside bundle dir
+	filePath := path.Join(bundleDir, fp.ToSlash(name))
+	if !strings.HasPrefix(filePath, bundleDir+"/") {
+		return "", fmt.Errorf("file %s is outside of post directory", name)
+	}
+
+	content, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// Pick the region, then the line ranges inside it
+	if region, exist := args["region"]; exist {
+		lines, err = extractRegion(lines, region)
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	if src, exist := args["lines"]; exist {
+		lines, err = extractLines(lines, src)
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	code := strings.Join(lines, "")
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+
+	if args["raw"] == "true" {
+		return code, nil
+	}
+
+	// Make sure the fence is longer than any backticks inside the code
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	info := args["lang"]
+	if info == "" {
+		info = detectLanguage(name)
+	}
+
+	attrs := []string{}
+	for _, attrName := range []string{"hl_lines", "linenos"} {
+		if value, exist := args[attrName]; exist {
+			attrs = append(attrs, fmt.Sprintf(`%s="%s"`, attrName, value))
+		}
+	}
+
+	if len(attrs) > 0 {
+		info += " {" + strings.Join(attrs, " ") + "}"
+	}
+
+	return "\n" + fence + info + "\n" + code + fence + "\n", nil
+}
+
+// renderGallery renders the images inside the directory of post or page, e.g.
+// {{< gallery >}} or {{< gallery "photos/*.jpg" >}}. The gallery is rendered using
+// shortcode-gallery.html template in theme, or using the default markup if the
+// template is not exist.
+func (rd Renderer) renderGallery(chain []model.Theme, pagePath string, args map[string]string) (string, error) {
+	pattern := args["match"]
+	if pattern == "" {
+		pattern = args["0"]
+	}
+
+	resources, err := rd.getResources(pagePath)
+	if err != nil {
+		return "", err
+	}
+
+	gallery := Gallery{PagePath: pagePath}
+	for _, resource := range resources {
+		if !resource.IsImage || strings.HasPrefix(path.Base(resource.Name), "_thumbnail.") {
+			continue
+		}
+
+		if pattern != "" {
+			matched, err := path.Match(pattern, resource.Name)
+			if err != nil {
+				return "", fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+
+			if !matched {
+				continue
+			}
+		}
+
+		gallery.Images = append(gallery.Images, resource)
+	}
+
+	buffer := bytes.Buffer{}
+	if tplGallery := findTemplate(chain, "shortcode-gallery.html"); tplGallery != nil {
+		tpl, err := rd.parseTemplates(tplGallery)
+		if err != nil {
+			return "", err
+		}
+
+		err = executeHook(&buffer, tpl, "shortcode-gallery.html", &gallery)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		buffer.WriteString(`<div class="gallery">`)
+		for _, image := range gallery.Images {
+			fmt.Fprintf(&buffer, `<figure><a href="%s"><img src="%s" alt="%s"></a></figure>`,
+				html.EscapeString(image.URL),
+				html.EscapeString(image.URL),
+				html.EscapeString(image.Name))
+		}
+		buffer.WriteString(`</div>`)
+	}
+
+	// Since the gallery is put inside markdown as HTML block, which ended
+	// by blank line, make sure there are no blank lines inside it.
+	lines := []string{}
+	for _, line := range strings.Split(buffer.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return "\n" + strings.Join(lines, "\n") + "\n\n", nil
+}
+
+// extractRegion returns the lines inside named region, which marked by comments
+// "#region name" and "#endregion". The marker lines of any region are removed.
+func extractRegion(lines []string, name string) ([]string, error) {
+	result := []string{}
+	depth := 0
+	found := false
+
+	for _, line := range lines {
+		switch {
+		case rxRegionEnd.MatchString(line):
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					return result, nil
+				}
+			}
+		case rxRegionStart.MatchString(line):
+			if depth > 0 {
+				depth++
+			} else if rxRegionStart.FindStringSubmatch(line)[1] == name {
+				depth = 1
+				found = true
+			}
+		case depth > 0:
+			result = append(result, line)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("region %s is not found", name)
+	}
+
+	return nil, fmt.Errorf("region %s is not closed", name)
+}
+
+// extractLines returns the lines in the specified ranges, e.g. "3-10" or "1,5-7".
+func extractLines(lines []string, src string) ([]string, error) {
+	ranges := parseLineRanges(src)
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("invalid line range %q", src)
+	}
+
+	result := []string{}
+	for _, lineRange := range ranges {
+		start, end := lineRange[0], lineRange[1]
+		if start > len(lines) {
+			return nil, fmt.Errorf("line %d is out of range, file only has %d lines", start, len(lines))
+		}
+
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		result = append(result, lines[start-1:end]...)
+	}
+
+	return result, nil
+}
+
+// detectLanguage detects the language of a file from its name.
+func detectLanguage(name string) string {
+	lexer := lexers.Match(fp.Base(name))
+	if lexer == nil {
+		return strings.TrimPrefix(fp.Ext(name), ".")
+	}
+
+	config := lexer.Config()
+	if len(config.Aliases) > 0 {
+		return config.Aliases[0]
+	}
+
+	return strings.ToLower(config.Name)
+}
@@ -0,0 +1,248 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-spook/spook/imaging"
+	"github.com/go-spook/spook/model"
+)
+
+// Transformer modifies the HTML document that generated from markdown content
+// of post or page. The transformers are executed in the order that specified in
+// config file, using the same document, so the HTML only parsed once.
+type Transformer interface {
+	Transform(doc *goquery.Document, ctx *TransformContext) error
+}
+
+// TransformerFunc is an adapter to allow the use of ordinary function as Transformer.
+type TransformerFunc func(doc *goquery.Document, ctx *TransformContext) error
+
+// Transform calls f(doc, ctx).
+func (f TransformerFunc) Transform(doc *goquery.Document, ctx *TransformContext) error {
+	return f(doc, ctx)
+}
+
+// TransformContext is the context of content that being transformed.
+// Transformer may put the data that extracted from content here.
+type TransformContext struct {
+	Config   model.Config
+	PagePath string
+	Images   *imaging.Processor
+	TOC      template.HTML
+}
+
+// DefaultTransformers is list of transformers that used
+// if it's not specified in config file.
+var DefaultTransformers = []string{"highlight", "heading", "responsive-image", "toc"}
+
+var (
+	transformersLock sync.RWMutex
+	transformers     = map[string]Transformer{}
+)
+
+func init() {
+	RegisterTransformer("highlight", TransformerFunc(highlightCode))
+	RegisterTransformer("heading", TransformerFunc(processHeadings))
+	RegisterTransformer("external-link", TransformerFunc(markExternalLinks))
+	RegisterTransformer("lazy-image", TransformerFunc(lazyLoadImages))
+	RegisterTransformer("responsive-image", TransformerFunc(responsiveImages))
+	RegisterTransformer("toc", TransformerFunc(extractTOC))
+}
+
+// RegisterTransformer registers transformer with specified name, so it can be
+// enabled in config file. If the name is already used, the old one is replaced.
+func RegisterTransformer(name string, transformer Transformer) {
+	transformersLock.Lock()
+	defer transformersLock.Unlock()
+
+	transformers[name] = transformer
+}
+
+// transformHTML executes the enabled transformers to HTML content.
+func (rd Renderer) transformHTML(content []byte, ctx *TransformContext) ([]byte, error) {
+	names := rd.Config.Markup.Transformers
+	if names == nil {
+		names = DefaultTransformers
+	}
+
+	if len(names) == 0 {
+		return content, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		transformersLock.RLock()
+		transformer, exist := transformers[name]
+		transformersLock.RUnlock()
+
+		if !exist {
+			return nil, fmt.Errorf("unknown transformer: %s", name)
+		}
+
+		err = transformer.Transform(doc, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("transformer %s failed: %v", name, err)
+		}
+	}
+
+	// Since the content is parsed as a complete document,
+	// only return the content of its body.
+	newHTML, err := doc.Find("body").Html()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(newHTML), nil
+}
+
+// markExternalLinks marks the links to external site, so
+// the opened page can't access the window of current page.
+func markExternalLinks(doc *goquery.Document, ctx *TransformContext) error {
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, err := url.Parse(a.AttrOr("href", ""))
+		if err != nil || href.Host == "" {
+			return
+		}
+
+		if href.Scheme != "" && href.Scheme != "http" && href.Scheme != "https" {
+			return
+		}
+
+		rel := strings.Fields(a.AttrOr("rel", ""))
+		for _, value := range []string{"noopener", "external"} {
+			exist := false
+			for _, existing := range rel {
+				if existing == value {
+					exist = true
+					break
+				}
+			}
+
+			if !exist {
+				rel = append(rel, value)
+			}
+		}
+
+		a.SetAttr("rel", strings.Join(rel, " "))
+	})
+
+	return nil
+}
+
+// lazyLoadImages makes the images only loaded when it's about to be visible.
+func lazyLoadImages(doc *goquery.Document, ctx *TransformContext) error {
+	doc.Find("img").Each(func(_ int, img *goquery.Selection) {
+		if _, exist := img.Attr("loading"); !exist {
+			img.SetAttr("loading", "lazy")
+		}
+	})
+
+	return nil
+}
+
+// responsiveImages adds srcset, width and height to the local images, using the
+// resized variants with the widths that specified in image config. The variants
+// are generated by the image processor, so they're ready to be written into
+// output dir once the build finished.
+func responsiveImages(doc *goquery.Document, ctx *TransformContext) error {
+	if ctx.Images == nil {
+		return nil
+	}
+
+	var err error
+	doc.Find("img[src]").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+		imageURL, isExternal := resolveURL(img.AttrOr("src", ""), ctx.PagePath)
+		if isExternal || !imaging.IsSupported(imageURL) {
+			return true
+		}
+
+		// Skip the image that not exist, e.g. the one that served by theme
+		width, height, errSize := ctx.Images.Size(imageURL)
+		if errSize != nil {
+			return true
+		}
+
+		if _, exist := img.Attr("srcset"); !exist && len(ctx.Config.Image.Widths) > 0 {
+			var srcset string
+			srcset, width, height, err = ctx.Images.Srcset(imageURL, ctx.Config.Image.Widths)
+			if err != nil {
+				return false
+			}
+
+			img.SetAttr("srcset", srcset)
+		}
+
+		_, hasWidth := img.Attr("width")
+		_, hasHeight := img.Attr("height")
+		if !hasWidth && !hasHeight {
+			img.SetAttr("width", strconv.Itoa(width))
+			img.SetAttr("height", strconv.Itoa(height))
+		}
+
+		return true
+	})
+
+	return err
+}
+
+// extractTOC creates table of contents from the headings which has ID.
+// Therefore, it should be executed after the heading transformer.
+func extractTOC(doc *goquery.Document, ctx *TransformContext) error {
+	buffer := bytes.Buffer{}
+	baseLevel, currentLevel := 0, 0
+
+	doc.Find("h1[id],h2[id],h3[id],h4[id],h5[id],h6[id]").Each(func(_ int, s *goquery.Selection) {
+		level := int(goquery.NodeName(s)[1] - '0')
+		if baseLevel == 0 {
+			baseLevel, currentLevel = level, level-1
+		}
+
+		if level < baseLevel {
+			level = baseLevel
+		}
+
+		// Open or close the nested lists following the heading level
+		switch {
+		case level > currentLevel:
+			for ; currentLevel < level; currentLevel++ {
+				buffer.WriteString("<ul><li>")
+			}
+		case level < currentLevel:
+			for ; currentLevel > level; currentLevel-- {
+				buffer.WriteString("</li></ul>")
+			}
+			buffer.WriteString("</li><li>")
+		default:
+			buffer.WriteString("</li><li>")
+		}
+
+		title := s.Clone()
+		title.Find("a.anchor").Remove()
+
+		fmt.Fprintf(&buffer, `<a href="#%s">%s</a>`,
+			html.EscapeString(s.AttrOr("id", "")),
+			html.EscapeString(strings.TrimSpace(title.Text())))
+	})
+
+	for ; currentLevel >= baseLevel && baseLevel > 0; currentLevel-- {
+		buffer.WriteString("</li></ul>")
+	}
+
+	if buffer.Len() > 0 {
+		ctx.TOC = template.HTML(`<nav class="toc">` + buffer.String() + `</nav>`)
+	}
+
+	return nil
+}
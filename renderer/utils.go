@@ -2,10 +2,7 @@ package renderer
 
 import (
 	"bytes"
-	"io/ioutil"
-	"net/http"
-	"os"
-	fp "path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -13,121 +10,154 @@ import (
 	fhtml "github.com/alecthomas/chroma/formatters/html"
 	"github.com/alecthomas/chroma/lexers"
 	"github.com/alecthomas/chroma/styles"
+	"github.com/go-spook/spook/model"
 )
 
-// fileExists returns true if file in path is exist.
-func fileExists(path string) bool {
-	if f, err := os.Stat(path); err == nil && !f.IsDir() && f.Size() > 0 {
-		return true
-	}
+// highlightCode highlights the code in generated HTML. The language of code
+// is taken from its class, and only guessed if the class is not specified.
+func highlightCode(doc *goquery.Document, ctx *TransformContext) error {
+	config := ctx.Config.Markup.Highlight
+	style := highlightStyle(config)
+
+	doc.Find("pre>code").Each(func(_ int, cd *goquery.Selection) {
+		text := cd.Text()
+		text = strings.TrimSuffix(text, "\n")
+
+		var lexer chroma.Lexer
+		for _, class := range strings.Fields(cd.AttrOr("class", "")) {
+			if strings.HasPrefix(class, "language-") {
+				lexer = lexers.Get(strings.TrimPrefix(class, "language-"))
+				break
+			}
+		}
+
+		if lexer == nil {
+			lexer = lexers.Analyse(text)
+		}
+
+		if lexer == nil {
+			lexer = lexers.Fallback
+		}
+		lexer = chroma.Coalesce(lexer)
+
+		iterator, err := lexer.Tokenise(nil, text)
+		if err != nil {
+			return
+		}
+
+		// Line numbers and highlighted lines can be specified per code block
+		if lineNumbers, exist := cd.Attr("data-linenos"); exist {
+			config.LineNumbers = lineNumbers != "false"
+		}
+
+		options := highlightOptions(config)
+		if hlLines := parseLineRanges(cd.AttrOr("data-hl-lines", "")); len(hlLines) > 0 {
+			options = append(options, fhtml.HighlightLines(hlLines))
+		}
+
+		output := bytes.Buffer{}
+		err = fhtml.New(options...).Format(&output, style, iterator)
+		if err != nil {
+			return
+		}
 
-	return false
+		cd.RemoveAttr("data-linenos")
+		cd.RemoveAttr("data-hl-lines")
+		cd.SetHtml(output.String())
+	})
+
+	return nil
 }
 
-// readIndexFile reads content of _index.md file in specified directory
-func readIndexFile(dir string) ([]byte, error) {
-	indexFile, err := os.Open(fp.Join(dir, "_index.md"))
-	if err != nil {
-		return nil, err
+// highlightStyle returns chroma style that specified in config.
+func highlightStyle(config model.HighlightConfig) *chroma.Style {
+	if config.Style == "" {
+		return styles.Fallback
 	}
-	defer indexFile.Close()
 
-	return ioutil.ReadAll(indexFile)
+	return styles.Get(config.Style)
 }
 
-// removeMetadata removes metadata from specified content
-func removeMetadata(content []byte) []byte {
-	origin := content
-	if !bytes.HasPrefix(content, []byte("+++\n")) {
-		return origin
+// highlightOptions returns options for chroma HTML formatter following the config.
+func highlightOptions(config model.HighlightConfig) []fhtml.Option {
+	options := []fhtml.Option{}
+	if !config.InlineStyle {
+		options = append(options, fhtml.WithClasses())
+	}
+
+	if config.LineNumbers {
+		options = append(options, fhtml.WithLineNumbers())
 	}
 
-	content = bytes.TrimPrefix(content, []byte("+++\n"))
-	separatorIdx := bytes.Index(content, []byte("+++\n"))
-	if separatorIdx == -1 {
-		return origin
+	if config.TabWidth > 0 {
+		options = append(options, fhtml.TabWidth(config.TabWidth))
 	}
 
-	return content[separatorIdx+3:]
+	return options
 }
 
-// getThumbnailFile fetch thumbnail file in specified directory
-func getThumbnailFile(dir string) string {
-	items, err := ioutil.ReadDir(dir)
-	if err != nil {
-		return ""
-	}
+// parseLineRanges parses line ranges like "1 3-5 8" or "1,3-5,8" into
+// list of inclusive ranges. The invalid ranges are skipped.
+func parseLineRanges(src string) [][2]int {
+	src = strings.Replace(src, ",", " ", -1)
 
-	for _, item := range items {
-		if item.IsDir() {
+	ranges := [][2]int{}
+	for _, field := range strings.Fields(src) {
+		parts := strings.SplitN(field, "-", 2)
+
+		start, err := strconv.Atoi(parts[0])
+		if err != nil || start < 1 {
 			continue
 		}
 
-		if strings.HasPrefix(item.Name(), "_thumbnail.") {
-			imgPath := fp.Join(dir, item.Name())
-			if isImageFile(imgPath) {
-				return item.Name()
+		end := start
+		if len(parts) == 2 {
+			end, err = strconv.Atoi(parts[1])
+			if err != nil || end < start {
+				continue
 			}
 		}
-	}
 
-	return ""
-}
-
-// isImageFile check file's header and see if it is image
-func isImageFile(path string) bool {
-	// Open file
-	f, err := os.Open(path)
-	if err != nil {
-		return false
-	}
-	defer f.Close()
-
-	// Read the first 512 bytes
-	buffer := make([]byte, 512)
-	_, err = f.Read(buffer)
-	if err != nil {
-		return false
+		ranges = append(ranges, [2]int{start, end})
 	}
 
-	// Get mime type
-	mimeType := http.DetectContentType(buffer)
-	return strings.HasPrefix(mimeType, "image/")
+	return ranges
 }
 
-// highlightCode highlights the code in generated HTML
-func highlightCode(html []byte) []byte {
-	r := bytes.NewReader(html)
-	doc, err := goquery.NewDocumentFromReader(r)
-	if err != nil {
-		return html
+// levenshtein returns the number of single character edits which
+// needed to change string a into string b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
 	}
 
-	formatter := fhtml.New(fhtml.WithClasses())
-	doc.Find("pre>code").Each(func(_ int, cd *goquery.Selection) {
-		text := cd.Text()
-		text = strings.TrimSuffix(text, "\n")
-
-		lexer := lexers.Analyse(text)
-		if lexer == nil {
-			lexer = lexers.Fallback
-		}
-		lexer = chroma.Coalesce(lexer)
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
 
-		iterator, err := lexer.Tokenise(nil, text)
-		if err != nil {
-			return
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
 		}
+		previous, current = current, previous
+	}
 
-		output := bytes.Buffer{}
-		err = formatter.Format(&output, styles.Fallback, iterator)
-		cd.SetHtml(output.String())
-	})
+	return previous[len(rb)]
+}
 
-	newHTML, err := doc.Html()
-	if err != nil {
-		return html
+// minInt returns the smallest of the numbers.
+func minInt(first int, others ...int) int {
+	min := first
+	for _, n := range others {
+		if n < min {
+			min = n
+		}
 	}
 
-	return []byte(newHTML)
+	return min
 }
@@ -0,0 +1,724 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	fp "path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-spook/spook/asset"
+	"github.com/go-spook/spook/imaging"
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/output"
+	"github.com/go-spook/spook/renderer"
+)
+
+// BuildResult is the result of building site. Jobs is listed in the order they're
+// prepared, while Images is the resized images that written, relative to output dir.
+type BuildResult struct {
+	Jobs   []JobResult
+	Images []string
+}
+
+// JobResult is the result of a job in build. Built is false if the job is skipped
+// since it's up to date, while Outputs is the files that written by it, either in
+// this build or the last build, relative to output dir.
+type JobResult struct {
+	Name    string
+	Kind    string
+	Built   bool
+	Outputs []string
+}
+
+// Build builds the site into output dir. Only the files which inputs are changed since
+// the last build are rendered again, unless the site is loaded with FullBuild option.
+// If context is canceled, the build is stopped and the error of context is returned.
+func (site *Site) Build(ctx context.Context, outputDir string) (BuildResult, error) {
+	return site.BuildTo(ctx, output.NewDir(outputDir))
+}
+
+// BuildTo builds the site into output sink. The build is only incremental if the sink
+// is an output dir, since the files from the last build must be kept. For the other
+// sinks, e.g. in memory, every file is rendered and written into the sink.
+func (site *Site) BuildTo(ctx context.Context, sink output.Sink) (BuildResult, error) {
+	result := BuildResult{}
+
+	// Load the manifest of last build. If it's not exist
+	// or the build is forced to be full, clean the output dir.
+	manifestPath := fp.Join(site.RootDir, ".cache", "build.json")
+	lastBuild := newBuildManifest("")
+	dir, incremental := sink.(*output.Dir)
+	if incremental {
+		outputDir, err := fp.Abs(dir.Path)
+		if err != nil {
+			return result, newError(IOError, err, "failed to get output dir")
+		}
+
+		err = os.MkdirAll(outputDir, os.ModePerm)
+		if err != nil {
+			return result, newError(IOError, err, "failed to create output dir")
+		}
+
+		dir = output.NewDir(outputDir)
+		sink = dir
+
+		lastBuild, err = loadBuildManifest(manifestPath)
+		if site.options.FullBuild || err != nil || lastBuild.OutputDir != outputDir {
+			lastBuild = newBuildManifest(outputDir)
+			err = dir.Clean()
+			if err != nil {
+				return result, newError(IOError, err, "failed to clean output dir")
+			}
+		}
+
+		// Remove the manifest while building, so if the build
+		// failed, the next build will be a full rebuild.
+		err = os.RemoveAll(manifestPath)
+		if err != nil {
+			return result, newError(IOError, err, "failed to remove build manifest")
+		}
+	}
+
+	// Prepare the jobs for building the site
+	rd := site.Renderer
+	hasher := newInputHasher(site.FS)
+	out := outputWriter{sink: sink, buildTime: site.options.BuildTime}
+	fileJobs, err := prepareFileJobs(hasher, site.FS, out, site.Themes, site.Assets)
+	if err != nil {
+		return result, newError(IOError, err, "failed to prepare static and theme files")
+	}
+
+	renderJobs, err := prepareRenderJobs(hasher, rd, out, site.Themes)
+	if err != nil {
+		return result, newError(IOError, err, "failed to prepare the build")
+	}
+
+	// Run all jobs using the worker pool. The static and theme files
+	// are copied first, so the rendered files are never replaced by them.
+	currentBuild := newBuildManifest(lastBuild.OutputDir)
+	currentBuild.Inputs = hasher.hashes
+
+	err = site.runBuildJobs(ctx, "files", fileJobs, lastBuild, &currentBuild, &result)
+	if err == nil {
+		err = site.runBuildJobs(ctx, "render", renderJobs, lastBuild, &currentBuild, &result)
+	}
+
+	if errs, ok := err.(JobErrors); ok {
+		message := fmt.Sprintf("build failed with %d errors", len(errs))
+		return result, newError(errs.Kind(), errs, message)
+	} else if err != nil {
+		return result, err
+	}
+
+	// Write resized images that generated while rendering. Since the rendering is
+	// skipped for unchanged contents, keep the variants from the last build as long
+	// as their original image still exists. The variants that already written by
+	// the last build from the same cached file are not copied again.
+	site.progress(Progress{Phase: "images"})
+	for _, job := range result.Jobs[len(fileJobs):] {
+		if !job.Built {
+			err = keepImageVariants(rd.Images, lastBuild, currentBuild)
+			if err != nil {
+				return result, newError(IOError, err, "failed to keep resized images")
+			}
+			break
+		}
+	}
+
+	err = rd.Images.WriteVariants(sink, out.buildTime, lastBuild.writtenVariants())
+	if err != nil {
+		return result, newError(IOError, err, "failed to write resized images")
+	}
+
+	result.Images = []string{}
+	for _, variantURL := range rd.Images.Variants() {
+		result.Images = append(result.Images, strings.TrimPrefix(variantURL, "/"))
+	}
+	currentBuild.Jobs["resized images"] = buildRecord{Outputs: result.Images}
+	currentBuild.Variants = rd.Images.VariantFiles()
+
+	if !incremental {
+		return result, nil
+	}
+
+	// Remove the files that no longer generated, then save the manifest
+	site.progress(Progress{Phase: "cleanup"})
+	for _, file := range lastBuild.staleOutputs(currentBuild) {
+		err = dir.Remove(file)
+		if err != nil {
+			return result, newError(IOError, err, "failed to remove stale files")
+		}
+	}
+
+	err = currentBuild.save(manifestPath)
+	if err != nil {
+		return result, newError(IOError, err, "failed to save build manifest")
+	}
+
+	return result, nil
+}
+
+// prepareFileJobs prepares the job for copying static directory and theme files,
+// including the processed assets, into output sink.
+func prepareFileJobs(hasher *inputHasher, fsys fs.FS, out outputWriter, themeChain []model.Theme, assets *asset.Manifest) ([]job, error) {
+	// Collect the static and theme files, along with their output
+	staticFiles := []string{}
+	if dirExists(fsys, "static") {
+		files, err := listFiles(fsys, "static")
+		if err != nil {
+			return nil, err
+		}
+		staticFiles = files
+	}
+
+	srcFiles := append([]string{}, staticFiles...)
+	dstFiles := append([]string{}, staticFiles...)
+	for _, item := range themeChain {
+		files, err := listThemeFiles(item)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			srcFiles = append(srcFiles, path.Join(item.Path, file))
+		}
+		dstFiles = append(dstFiles, files...)
+	}
+
+	inputs, err := hasher.files(append(srcFiles, "config.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	dstFiles = append(dstFiles, assets.Files()...)
+
+	return []job{{
+		Name:   "static and theme files",
+		Kind:   "file",
+		Inputs: uniqueStrings(inputs),
+		Run: func() ([]string, error) {
+			for _, file := range staticFiles {
+				err := out.copyFile(fsys, file)
+				if err != nil {
+					return nil, fmt.Errorf("failed to copy static directory: %w", err)
+				}
+			}
+
+			err := copyThemeDirs(themeChain, out)
+			if err != nil {
+				return nil, fmt.Errorf("failed to copy theme files: %w", err)
+			}
+
+			err = assets.Write(out.sink, out.buildTime)
+			if err != nil {
+				return nil, newError(IOError, err, "failed to write assets")
+			}
+
+			return uniqueStrings(dstFiles), nil
+		},
+	}}, nil
+}
+
+// prepareRenderJobs prepares the jobs for rendering front page, lists, pages and posts.
+// Each job depends on the config file, templates, processed assets and list of pages,
+// since they are used by all templates, and on the contents that rendered by it.
+func prepareRenderJobs(hasher *inputHasher, rd renderer.Renderer, out outputWriter, themeChain []model.Theme) ([]job, error) {
+	// Collect the inputs that used by all jobs
+	commonFiles := []string{"config.toml"}
+	for _, item := range themeChain {
+		themeItems, err := fs.ReadDir(item.FS, ".")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, themeItem := range themeItems {
+			if !themeItem.IsDir() {
+				commonFiles = append(commonFiles, path.Join(item.Path, themeItem.Name()))
+			}
+		}
+	}
+
+	commonInputs, err := hasher.files(commonFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	commonInputs = append(commonInputs,
+		hasher.value("assets", rd.Assets.Files()),
+		hasher.value("pages", rd.Pages))
+
+	// Helper functions for declaring inputs
+	postInputs := func(posts []model.Post) []string {
+		inputs := append([]string{}, commonInputs...)
+		for _, post := range posts {
+			inputs = append(inputs, hasher.value("post:"+post.Path, post))
+		}
+		return uniqueStrings(inputs)
+	}
+
+	contentInputs := func(contentPath string, references []string, extraInputs ...string) ([]string, error) {
+		files, err := listFiles(rd.FS, strings.TrimPrefix(contentPath, "/"))
+		if err != nil {
+			return nil, err
+		}
+
+		inputs, err := hasher.files(files)
+		if err != nil {
+			return nil, err
+		}
+
+		inputs = append(inputs, commonInputs...)
+		inputs = append(inputs, extraInputs...)
+		for _, linkedPath := range rd.LinkedContents(contentPath, references) {
+			inputs = append(inputs, hasher.value("linked:"+linkedPath, linkedContent(rd, linkedPath)))
+		}
+
+		return uniqueStrings(inputs), nil
+	}
+
+	// Prepare jobs for front page and lists
+	frontPageInputs := append(postInputs(rd.Posts),
+		hasher.value("groups", []interface{}{rd.Categories, rd.Tags}))
+
+	jobs := []job{{
+		Name:   "front page",
+		Kind:   "front page",
+		Inputs: uniqueStrings(frontPageInputs),
+		Run: func() ([]string, error) {
+			return []string{"index.html"}, buildFrontPage(rd, out)
+		},
+	}, {
+		Name:   "list of posts",
+		Kind:   "list",
+		Inputs: postInputs(rd.Posts),
+		Run: func() ([]string, error) {
+			return buildList(rd, out, "posts", renderer.DEFAULT, "")
+		},
+	}}
+
+	for _, category := range rd.Categories {
+		categoryName := category.Name
+		if categoryName == "" {
+			categoryName = "uncategorized"
+		}
+
+		categoryPosts := []model.Post{}
+		for _, post := range rd.Posts {
+			if post.Category == category.Name {
+				categoryPosts = append(categoryPosts, post)
+			}
+		}
+
+		jobs = append(jobs, job{
+			Name:   fmt.Sprintf("list category \"%s\"", categoryName),
+			Kind:   "category",
+			Inputs: postInputs(categoryPosts),
+			Run: func() ([]string, error) {
+				listDir := path.Join("category", categoryName)
+				return buildList(rd, out, listDir, renderer.CATEGORY, categoryName)
+			},
+		})
+	}
+
+	for _, tag := range rd.Tags {
+		tagName := tag.Name
+
+		tagPosts := []model.Post{}
+		for _, post := range rd.Posts {
+			for _, postTag := range post.Tags {
+				if postTag == tagName {
+					tagPosts = append(tagPosts, post)
+					break
+				}
+			}
+		}
+
+		jobs = append(jobs, job{
+			Name:   fmt.Sprintf("list tag \"%s\"", tagName),
+			Kind:   "tag",
+			Inputs: postInputs(tagPosts),
+			Run: func() ([]string, error) {
+				listDir := path.Join("tag", tagName)
+				return buildList(rd, out, listDir, renderer.TAG, tagName)
+			},
+		})
+	}
+
+	// Prepare jobs for pages and posts
+	for _, page := range rd.Pages {
+		page := page
+		inputs, err := contentInputs(page.Path, page.References)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job{
+			Name:   strings.TrimPrefix(page.Path, "/"),
+			Kind:   "page",
+			Inputs: inputs,
+			Run:    func() ([]string, error) { return buildPage(rd, out, page) },
+		})
+	}
+
+	posts := rd.Posts
+	for i := range posts {
+		post := posts[i]
+		newerPost := model.Post{}
+		olderPost := model.Post{}
+
+		if i > 0 {
+			newerPost = posts[i-1]
+		}
+
+		if i < len(posts)-1 {
+			olderPost = posts[i+1]
+		}
+
+		inputs, err := contentInputs(post.Path, post.References,
+			hasher.value("older:"+post.Path, olderPost),
+			hasher.value("newer:"+post.Path, newerPost))
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job{
+			Name:   strings.TrimPrefix(post.Path, "/"),
+			Kind:   "post",
+			Inputs: inputs,
+			Run:    func() ([]string, error) { return buildPost(rd, out, post, olderPost, newerPost) },
+		})
+	}
+
+	return jobs, nil
+}
+
+// linkedContent returns the post or page in contentPath.
+func linkedContent(rd renderer.Renderer, contentPath string) interface{} {
+	for _, post := range rd.Posts {
+		if fp.ToSlash(post.Path) == contentPath {
+			return post
+		}
+	}
+
+	for _, page := range rd.Pages {
+		if fp.ToSlash(page.Path) == contentPath {
+			return page
+		}
+	}
+
+	return nil
+}
+
+// keepImageVariants registers the variants that written in last build into the image
+// processor, as long as their original image is still written in the current build.
+// It's needed since the variants are generated while rendering, which is skipped
+// for the unchanged contents.
+func keepImageVariants(images *imaging.Processor, lastBuild, currentBuild buildManifest) error {
+	outputs := currentBuild.outputs()
+	for _, variantFile := range lastBuild.Jobs["resized images"].Outputs {
+		imageURL, _, ok := imaging.ParseVariantURL("/" + variantFile)
+		if !ok {
+			continue
+		}
+
+		if _, exist := outputs[strings.TrimPrefix(imageURL, "/")]; !exist {
+			continue
+		}
+
+		_, err := images.CachedFile("/" + variantFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyThemeDirs(themeChain []model.Theme, out outputWriter) error {
+	// Copy from the topmost parent, so the files in child theme
+	// will replace the files with same name in its parent.
+	for i := len(themeChain) - 1; i >= 0; i-- {
+		files, err := listThemeFiles(themeChain[i])
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			err = out.copyFile(themeChain[i].FS, file)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// listThemeFiles returns the files inside the sub directories of theme,
+// which are the directories that copied into output.
+func listThemeFiles(th model.Theme) ([]string, error) {
+	themeItems, err := fs.ReadDir(th.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, item := range themeItems {
+		if !item.IsDir() {
+			continue
+		}
+
+		dirFiles, err := listFiles(th.FS, item.Name())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+
+	return files, nil
+}
+
+func buildFrontPage(rd renderer.Renderer, out outputWriter) error {
+	err := out.writeFile("index.html", rd.RenderFrontPage)
+	if err != nil {
+		return fmt.Errorf("render failed: %w", err)
+	}
+
+	return nil
+}
+
+func buildList(rd renderer.Renderer, out outputWriter, listDir string, listType renderer.ListType, groupName string) ([]string, error) {
+	outputs := []string{}
+	for i := 0; ; i++ {
+		fileName := "index.html"
+		if i > 0 {
+			fileName = fmt.Sprintf("%d.html", i)
+		}
+		fileName = path.Join(listDir, fileName)
+
+		// Render into buffer first, since the file is
+		// not written if the page number is out of range.
+		buffer := bytes.Buffer{}
+		nPosts, err := rd.RenderList(listType, groupName, i, &buffer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build list of posts: %w", err)
+		}
+
+		if nPosts == -1 {
+			break
+		}
+
+		err = out.writeFile(fileName, func(w io.Writer) error {
+			_, err := buffer.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		outputs = append(outputs, fileName)
+	}
+
+	return outputs, nil
+}
+
+func buildPage(rd renderer.Renderer, out outputWriter, page model.Page) ([]string, error) {
+	page.Path = strings.TrimPrefix(page.Path, "/")
+
+	outputs, err := copyContentDir(rd, out, page.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	indexName := path.Join(page.Path, "index.html")
+	err = out.writeFile(indexName, func(w io.Writer) error {
+		return rd.RenderPage(page, w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return uniqueStrings(append(outputs, indexName)), nil
+}
+
+func buildPost(rd renderer.Renderer, out outputWriter, post, olderPost, newerPost model.Post) ([]string, error) {
+	post.Path = strings.TrimPrefix(post.Path, "/")
+
+	outputs, err := copyContentDir(rd, out, post.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	indexName := path.Join(post.Path, "index.html")
+	err = out.writeFile(indexName, func(w io.Writer) error {
+		return rd.RenderPost(post, olderPost, newerPost, w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return uniqueStrings(append(outputs, indexName)), nil
+}
+
+// copyContentDir copies the files inside directory of post or page into output sink,
+// then returns the copied files. If enabled, the EXIF metadata is removed from the
+// JPEG images while copying.
+func copyContentDir(rd renderer.Renderer, out outputWriter, contentPath string) ([]string, error) {
+	files, err := listFiles(rd.FS, contentPath)
+	if err != nil {
+		return nil, newError(IOError, err, "failed to copy files")
+	}
+
+	outputs := []string{}
+	for _, file := range files {
+		if path.Base(file) == "_index.md" {
+			continue
+		}
+
+		ext := strings.ToLower(path.Ext(file))
+		if rd.Config.Image.StripEXIF && (ext == ".jpg" || ext == ".jpeg") {
+			err = out.copyStrippedImage(rd.FS, file)
+		} else {
+			err = out.copyFile(rd.FS, file)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		outputs = append(outputs, file)
+	}
+
+	return outputs, nil
+}
+
+// job is a single unit of work in building the site, e.g. rendering a post. Kind is
+// the type of output that built by the job, e.g. "post" or "tag". Inputs is the list
+// of inputs that used by the job, which must be sorted, while Run returns the files
+// that written by the job, relative to output dir.
+type job struct {
+	Name   string
+	Kind   string
+	Inputs []string
+	Run    func() ([]string, error)
+}
+
+// runBuildJobs runs the jobs that not up to date since the last build, then records
+// them into the current build and the result. The returned error is either JobErrors
+// from the jobs that failed, or the error of context if it's canceled.
+func (site *Site) runBuildJobs(ctx context.Context, phase string, jobs []job, lastBuild buildManifest, currentBuild *buildManifest, result *BuildResult) error {
+	site.progress(Progress{Phase: phase, Total: len(jobs)})
+
+	nSkipped := 0
+	staleJobs := []job{}
+	for _, job := range jobs {
+		if lastBuild.isUpToDate(job, currentBuild.Inputs) {
+			nSkipped++
+			currentBuild.Jobs[job.Name] = lastBuild.Jobs[job.Name]
+			site.progress(Progress{
+				Phase:   phase,
+				Job:     job.Name,
+				Done:    nSkipped,
+				Total:   len(jobs),
+				Skipped: true,
+			})
+			continue
+		}
+		staleJobs = append(staleJobs, job)
+	}
+
+	outputs, err := site.runJobs(ctx, phase, staleJobs, nSkipped, len(jobs))
+
+	stale := map[string]struct{}{}
+	for i, job := range staleJobs {
+		stale[job.Name] = struct{}{}
+		currentBuild.Jobs[job.Name] = buildRecord{
+			Inputs:  job.Inputs,
+			Outputs: outputs[i],
+		}
+	}
+
+	for _, job := range jobs {
+		_, isStale := stale[job.Name]
+		result.Jobs = append(result.Jobs, JobResult{
+			Name:    job.Name,
+			Kind:    job.Kind,
+			Built:   isStale,
+			Outputs: currentBuild.Jobs[job.Name].Outputs,
+		})
+	}
+
+	return err
+}
+
+// runJobs runs the jobs using a bounded number of workers. Instead of stopping at the
+// first error, all jobs are executed and their errors are collected as JobErrors, in
+// the same order as the jobs so the result is deterministic. If context is canceled,
+// the remaining jobs are not started and the error of context is returned. Each
+// finished job is reported as progress of the phase, counted after done jobs.
+func (site *Site) runJobs(ctx context.Context, phase string, jobs []job, done int, total int) ([][]string, error) {
+	outputs := make([][]string, len(jobs))
+	results := make([]*JobError, len(jobs))
+	indexes := make(chan int)
+	mutex := sync.Mutex{}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < site.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				jobOutputs, err := jobs[idx].Run()
+				if err != nil {
+					results[idx] = &JobError{Job: jobs[idx].Name, Err: err}
+				}
+				outputs[idx] = jobOutputs
+
+				mutex.Lock()
+				done++
+				site.progress(Progress{
+					Phase: phase,
+					Job:   jobs[idx].Name,
+					Done:  done,
+					Total: total,
+					Err:   err,
+				})
+				mutex.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for i := range jobs {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return outputs, err
+	}
+
+	errors := JobErrors{}
+	for _, err := range results {
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) > 0 {
+		return outputs, errors
+	}
+
+	return outputs, nil
+}
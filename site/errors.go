@@ -0,0 +1,96 @@
+package site
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotFound is returned by Render when there is no page for the URL path.
+var ErrNotFound = errors.New("page is not found")
+
+// ErrorKind is the cause of error that returned while loading or building site.
+type ErrorKind int
+
+const (
+	// ConfigError means the config file is missing or invalid.
+	ConfigError ErrorKind = iota + 1
+	// ContentError means some posts or pages are invalid.
+	ContentError
+	// TemplateError means the theme or its templates are invalid.
+	TemplateError
+	// IOError means failure while reading or writing files.
+	IOError
+)
+
+// Error is an error that returned by Load and Build. If the error is caused by many
+// posts or jobs, Err is either parser.ContentErrors or JobErrors.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+// Error returns the message of the error, followed by its cause if any.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+func newError(kind ErrorKind, err error, message string) error {
+	return &Error{
+		Kind:    kind,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// JobError is an error that returned by a failed job, e.g. rendering a post.
+type JobError struct {
+	Job string
+	Err error
+}
+
+// Error returns the name of the job followed by its error.
+func (e *JobError) Error() string {
+	return e.Job + ": " + e.Err.Error()
+}
+
+// Kind returns the kind of the job error. The error that not caused by reading or
+// writing files, e.g. failure while executing template, is considered as TemplateError.
+func (e *JobError) Kind() ErrorKind {
+	var siteErr *Error
+	if errors.As(e.Err, &siteErr) {
+		return siteErr.Kind
+	}
+
+	return TemplateError
+}
+
+// JobErrors is list of errors from the failed jobs, in the same order as the jobs.
+type JobErrors []*JobError
+
+// Kind returns the most severe kind of the job errors. IOError is the most
+// severe, since it's caused by the environment instead of the site itself.
+func (errs JobErrors) Kind() ErrorKind {
+	kind := ErrorKind(0)
+	for _, err := range errs {
+		if errKind := err.Kind(); errKind > kind {
+			kind = errKind
+		}
+	}
+
+	return kind
+}
+
+// Error returns all error messages, one in each line.
+func (errs JobErrors) Error() string {
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
@@ -0,0 +1,254 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"sort"
+	"strings"
+)
+
+// buildManifest records the inputs and outputs of the last build, so the next build
+// only needs to rerun the jobs whose inputs are changed. The inputs are either files
+// inside root dir, keyed by their path, or values that used while rendering, e.g.
+// metadata of a post, keyed by name that started with "@". The resized images are
+// recorded separately, by the cached file that copied as each of them.
+type buildManifest struct {
+	OutputDir string
+	Inputs    map[string]string
+	Jobs      map[string]buildRecord
+	Variants  map[string]string
+}
+
+// buildRecord is the list of inputs that used by a build job, and the list of
+// files that it written, relative to output dir.
+type buildRecord struct {
+	Inputs  []string
+	Outputs []string
+}
+
+// newBuildManifest returns an empty build manifest for output dir.
+func newBuildManifest(outputDir string) buildManifest {
+	return buildManifest{
+		OutputDir: outputDir,
+		Inputs:    map[string]string{},
+		Jobs:      map[string]buildRecord{},
+		Variants:  map[string]string{},
+	}
+}
+
+// loadBuildManifest loads the build manifest from file in path.
+func loadBuildManifest(path string) (buildManifest, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return buildManifest{}, err
+	}
+
+	manifest := buildManifest{}
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return buildManifest{}, err
+	}
+
+	if manifest.Inputs == nil {
+		manifest.Inputs = map[string]string{}
+	}
+
+	if manifest.Jobs == nil {
+		manifest.Jobs = map[string]buildRecord{}
+	}
+
+	if manifest.Variants == nil {
+		manifest.Variants = map[string]string{}
+	}
+
+	return manifest, nil
+}
+
+// save saves the build manifest into file in path.
+func (m buildManifest) save(path string) error {
+	content, err := json.MarshalIndent(&m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(fp.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// isUpToDate checks whether the job doesn't need to be rerun, i.e. it uses the same
+// inputs as the last build, none of them are changed and all of its outputs exist.
+func (m buildManifest) isUpToDate(job job, inputs map[string]string) bool {
+	record, exist := m.Jobs[job.Name]
+	if !exist || len(record.Inputs) != len(job.Inputs) {
+		return false
+	}
+
+	for i, input := range job.Inputs {
+		if record.Inputs[i] != input || m.Inputs[input] != inputs[input] {
+			return false
+		}
+	}
+
+	for _, output := range record.Outputs {
+		if !fileExists(fp.Join(m.OutputDir, fp.FromSlash(output))) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writtenVariants returns the cached file of resized images that written in the
+// build, keyed by their URL, as long as they still exist in output dir.
+func (m buildManifest) writtenVariants() map[string]string {
+	written := map[string]string{}
+	for variantURL, cachePath := range m.Variants {
+		name := strings.TrimPrefix(variantURL, "/")
+		if fileExists(fp.Join(m.OutputDir, fp.FromSlash(name))) {
+			written[variantURL] = cachePath
+		}
+	}
+
+	return written
+}
+
+// outputs returns all files that written in the build.
+func (m buildManifest) outputs() map[string]struct{} {
+	outputs := map[string]struct{}{}
+	for _, record := range m.Jobs {
+		for _, output := range record.Outputs {
+			outputs[output] = struct{}{}
+		}
+	}
+
+	return outputs
+}
+
+// staleOutputs returns the files that written by the last build,
+// but not written anymore in the current build, sorted by their name.
+func (m buildManifest) staleOutputs(current buildManifest) []string {
+	currentOutputs := current.outputs()
+
+	stale := []string{}
+	for output := range m.outputs() {
+		if _, exist := currentOutputs[output]; !exist {
+			stale = append(stale, output)
+		}
+	}
+	sort.Strings(stale)
+
+	return stale
+}
+
+// inputHasher computes the hash of build inputs. The hash of each input is
+// only computed once, since the same input might be used by many jobs.
+type inputHasher struct {
+	fsys   fs.FS
+	hashes map[string]string
+}
+
+// newInputHasher returns a new input hasher for site in fsys.
+func newInputHasher(fsys fs.FS) *inputHasher {
+	return &inputHasher{
+		fsys:   fsys,
+		hashes: map[string]string{},
+	}
+}
+
+// file hashes the content of file with the name, which is also its input name.
+func (h *inputHasher) file(name string) (string, error) {
+	if _, exist := h.hashes[name]; exist {
+		return name, nil
+	}
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	if err != nil {
+		return "", err
+	}
+
+	h.hashes[name] = hex.EncodeToString(hasher.Sum(nil))
+	return name, nil
+}
+
+// files hashes the content of files with the names, then returns their input names.
+func (h *inputHasher) files(names []string) ([]string, error) {
+	inputs := []string{}
+	for _, name := range names {
+		input, err := h.file(name)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// value hashes the value, then returns the input name for it.
+func (h *inputHasher) value(name string, value interface{}) string {
+	name = "@" + name
+	if _, exist := h.hashes[name]; exist {
+		return name
+	}
+
+	content, _ := json.Marshal(value)
+	hash := sha256.Sum256(content)
+	h.hashes[name] = hex.EncodeToString(hash[:])
+	return name
+}
+
+// listFiles returns name of all files inside dir of fsys, except symlinks,
+// which are the files that copied into output.
+func listFiles(fsys fs.FS, dir string) ([]string, error) {
+	files := []string{}
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		files = append(files, name)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// uniqueStrings sorts the strings and removes the duplicates.
+func uniqueStrings(strs []string) []string {
+	sorted := append([]string{}, strs...)
+	sort.Strings(sorted)
+
+	result := []string{}
+	for i, str := range sorted {
+		if i == 0 || str != sorted[i-1] {
+			result = append(result, str)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,402 @@
+// Package site loads, renders and builds a spook site. It's used by both the build
+// command and the webserver, so a site is rendered the same way by both of them.
+package site
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	fp "path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-spook/spook/asset"
+	"github.com/go-spook/spook/imaging"
+	"github.com/go-spook/spook/model"
+	"github.com/go-spook/spook/parser"
+	"github.com/go-spook/spook/renderer"
+	"github.com/go-spook/spook/theme"
+)
+
+// Options is the options for loading and building site.
+type Options struct {
+	// FS is the file system that the site is read from, e.g. a zip file or a
+	// git tree. If it's nil, the site is read from its root dir. The root dir
+	// is still used for the cache, e.g. the resized images.
+	FS fs.FS
+
+	// Workers is the number of workers for converting and rendering
+	// the contents. If it's not positive, the number of CPU is used.
+	Workers int
+
+	// KeepGoing skips the invalid posts and pages, including the ones that failed
+	// to be converted, e.g. because of broken wiki link, instead of failing to load
+	// the site. The errors in the skipped contents are reported to Progress.
+	KeepGoing bool
+
+	// FullBuild forces Build to clean the output dir and render every file,
+	// instead of only rebuilding the files which inputs are changed.
+	FullBuild bool
+
+	// Minimize minifies the rendered HTML.
+	Minimize bool
+
+	// BuildTime is the modification time of the rendered files. The copied files keep
+	// their original modification time, unless it's after the build time. If it's
+	// zero, the current time is used.
+	BuildTime time.Time
+
+	// Progress is called whenever a phase is started and whenever a job in the phase
+	// is finished. It's never called concurrently, so it doesn't need to be synchronized.
+	Progress func(Progress)
+}
+
+// Progress is the progress of loading or building site. When a phase is started Job is
+// empty, while Total is the number of jobs in the phase. Then, each time a job is
+// finished or skipped since it's up to date, Job is its name and Done is increased.
+// When some contents are skipped, Warnings contains their errors.
+type Progress struct {
+	Phase    string
+	Job      string
+	Done     int
+	Total    int
+	Skipped  bool
+	Err      error
+	Warnings parser.ContentErrors
+}
+
+// Site is a site that loaded from root dir, with its posts and pages already parsed.
+// FS is the file system that the site is read from.
+type Site struct {
+	RootDir  string
+	FS       fs.FS
+	Config   model.Config
+	Themes   []model.Theme
+	Assets   *asset.Manifest
+	Renderer renderer.Renderer
+
+	options Options
+}
+
+// Load loads the site in root dir. It reads the config file, processes the assets
+// of the theme, then parses and converts all posts and pages.
+func Load(rootDir string, opts Options) (*Site, error) {
+	return LoadContext(context.Background(), rootDir, opts)
+}
+
+// LoadContext is like Load, but if context is canceled while converting the
+// contents, the loading is stopped and the error of context is returned.
+func LoadContext(ctx context.Context, rootDir string, opts Options) (*Site, error) {
+	if opts.Workers < 1 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	if opts.BuildTime.IsZero() {
+		opts.BuildTime = time.Now()
+	}
+
+	rootDir, err := fp.Abs(rootDir)
+	if err != nil {
+		return nil, newError(IOError, err, "failed to get root dir")
+	}
+
+	site := &Site{
+		RootDir: rootDir,
+		FS:      opts.FS,
+		options: opts,
+	}
+
+	if site.FS == nil {
+		site.FS = os.DirFS(rootDir)
+	}
+
+	// Open config file and make sure its theme is valid
+	site.progress(Progress{Phase: "prepare"})
+	config, err := fs.ReadFile(site.FS, "config.toml")
+	if err != nil {
+		return nil, newError(ConfigError, err, "failed to open config file")
+	}
+
+	_, err = toml.Decode(string(config), &site.Config)
+	if err != nil {
+		return nil, newError(ConfigError, err, "failed to open config file")
+	}
+
+	if site.Config.Theme == "" {
+		return nil, newError(ConfigError, nil, "no theme specified in config file")
+	}
+
+	err = theme.Validate(site.FS, site.Config.Theme)
+	if err != nil {
+		return nil, newError(TemplateError, err, "invalid theme")
+	}
+
+	site.Themes, err = theme.Chain(site.FS, site.Config.Theme)
+	if err != nil {
+		return nil, newError(TemplateError, err, "failed to read theme dir")
+	}
+
+	// Process asset files, e.g. CSS and JS, in theme
+	site.Assets, err = asset.Process(site.Themes, site.Config.Assets)
+	if err != nil {
+		return nil, newError(TemplateError, err, "failed to process assets")
+	}
+
+	// Parse all posts and pages
+	site.progress(Progress{Phase: "parse"})
+	psr := parser.Parser{
+		Config: site.Config,
+		FS:     site.FS,
+	}
+
+	contentErrors := parser.ContentErrors{}
+	parsedPosts, err := psr.ParsePosts()
+	if errs, ok := err.(parser.ContentErrors); ok {
+		contentErrors = append(contentErrors, errs...)
+	} else if err != nil {
+		return nil, newError(ContentError, err, "failed to parse posts")
+	}
+
+	pages, err := psr.ParsePages()
+	if errs, ok := err.(parser.ContentErrors); ok {
+		contentErrors = append(contentErrors, errs...)
+	} else if err != nil {
+		return nil, newError(ContentError, err, "failed to parse pages")
+	}
+
+	if len(contentErrors) > 0 {
+		if !opts.KeepGoing {
+			message := fmt.Sprintf("found %d errors in %d contents", len(contentErrors), len(contentErrors.Paths()))
+			return nil, newError(ContentError, contentErrors, message)
+		}
+		site.progress(Progress{Phase: "parse", Warnings: contentErrors})
+	}
+
+	// Create renderer, then convert the content of posts and pages
+	site.Renderer = renderer.Renderer{
+		Config:     site.Config,
+		Pages:      pages,
+		Posts:      parsedPosts.Posts,
+		Tags:       parsedPosts.Tags,
+		Categories: parsedPosts.Categories,
+		FS:         site.FS,
+		Minimize:   opts.Minimize,
+		Images:     imaging.New(site.FS, fp.Join(rootDir, ".cache", "images"), site.Config.Image),
+		Assets:     site.Assets,
+	}
+
+	err = site.convertContents(ctx, psr)
+	if err != nil {
+		return nil, err
+	}
+
+	return site, nil
+}
+
+// convertContents converts the markdown of posts and pages using the worker pool. Each
+// content is saved after all jobs finished, since the renderer reads the other posts
+// and pages while converting, e.g. to resolve wiki links. If KeepGoing option is set,
+// the posts and pages that failed to be converted are removed from the site.
+func (site *Site) convertContents(ctx context.Context, psr parser.Parser) error {
+	rd := &site.Renderer
+	postContents := make([]*model.Content, len(rd.Posts))
+	pageContents := make([]*model.Content, len(rd.Pages))
+
+	jobs := []job{}
+	for i, post := range rd.Posts {
+		i, post := i, post
+		jobs = append(jobs, job{
+			Name: strings.TrimPrefix(post.Path, "/"),
+			Kind: "post",
+			Run: func() (_ []string, err error) {
+				postContents[i], err = psr.ParseContent(post.Path, post.Content.Markdown, rd.ConvertMarkdown)
+				return nil, err
+			},
+		})
+	}
+
+	for i, page := range rd.Pages {
+		i, page := i, page
+		jobs = append(jobs, job{
+			Name: strings.TrimPrefix(page.Path, "/"),
+			Kind: "page",
+			Run: func() (_ []string, err error) {
+				pageContents[i], err = psr.ParseContent(page.Path, page.Content.Markdown, rd.ConvertMarkdown)
+				return nil, err
+			},
+		})
+	}
+
+	site.progress(Progress{Phase: "convert", Total: len(jobs)})
+	_, err := site.runJobs(ctx, "convert", jobs, 0, len(jobs))
+	if errs, ok := err.(JobErrors); ok {
+		if !site.options.KeepGoing {
+			message := fmt.Sprintf("failed to convert %d contents", len(errs))
+			return newError(ContentError, errs, message)
+		}
+
+		warnings := parser.ContentErrors{}
+		for _, err := range errs {
+			warnings = append(warnings, parser.ContentError{
+				Path:    path.Join(err.Job, "_index.md"),
+				Message: err.Err.Error(),
+			})
+		}
+		site.progress(Progress{Phase: "convert", Warnings: warnings})
+	} else if err != nil {
+		return err
+	}
+
+	// Save the converted contents, skipping the ones that failed
+	posts := []model.Post{}
+	for i, post := range rd.Posts {
+		if postContents[i] == nil {
+			continue
+		}
+
+		post.Content = postContents[i]
+		if post.Excerpt == "" {
+			post.Excerpt = post.Content.Summary
+		}
+		posts = append(posts, post)
+	}
+
+	pages := []model.Page{}
+	for i, page := range rd.Pages {
+		if pageContents[i] == nil {
+			continue
+		}
+
+		page.Content = pageContents[i]
+		if page.Excerpt == "" {
+			page.Excerpt = page.Content.Summary
+		}
+		pages = append(pages, page)
+	}
+
+	// If some posts are skipped, their categories and tags must be recounted
+	if len(posts) != len(rd.Posts) {
+		rd.Categories, rd.Tags = parser.Groups(posts)
+	}
+
+	rd.Posts = posts
+	rd.Pages = pages
+	return nil
+}
+
+// Render renders the HTML for URL path into w. The rendered HTML is the same as the
+// file that written by Build for the path, e.g. "/post/hello/" renders the same HTML as
+// "post/hello/index.html". If there is no page in the path, ErrNotFound is returned.
+func (site *Site) Render(urlPath string, w io.Writer) error {
+	urlPath = path.Clean("/" + urlPath)
+	if path.Base(urlPath) == "index.html" {
+		urlPath = path.Dir(urlPath)
+	}
+
+	rd := site.Renderer
+	parts := strings.Split(strings.Trim(urlPath, "/"), "/")
+
+	switch {
+	case urlPath == "/":
+		return rd.RenderFrontPage(w)
+
+	case parts[0] == "posts" && len(parts) <= 2:
+		return site.renderList(w, renderer.DEFAULT, "", parts[1:])
+
+	case parts[0] == "category" && len(parts) >= 2 && len(parts) <= 3:
+		if !hasGroup(rd.Categories, parts[1]) {
+			return ErrNotFound
+		}
+		return site.renderList(w, renderer.CATEGORY, parts[1], parts[2:])
+
+	case parts[0] == "tag" && len(parts) >= 2 && len(parts) <= 3:
+		if !hasGroup(rd.Tags, parts[1]) {
+			return ErrNotFound
+		}
+		return site.renderList(w, renderer.TAG, parts[1], parts[2:])
+
+	case parts[0] == "page" && len(parts) == 2:
+		for _, page := range rd.Pages {
+			if fp.ToSlash(page.Path) == urlPath {
+				page.Path = strings.TrimPrefix(page.Path, "/")
+				return rd.RenderPage(page, w)
+			}
+		}
+
+	case parts[0] == "post" && len(parts) == 2:
+		for i, post := range rd.Posts {
+			if fp.ToSlash(post.Path) == urlPath {
+				olderPost, newerPost := site.adjacentPosts(i)
+				post.Path = strings.TrimPrefix(post.Path, "/")
+				return rd.RenderPost(post, olderPost, newerPost, w)
+			}
+		}
+	}
+
+	return ErrNotFound
+}
+
+// renderList renders the list with page number in the optional file name, which is
+// either "N" or "N.html". The first page is rendered if file name is not specified.
+func (site *Site) renderList(w io.Writer, listType renderer.ListType, groupName string, fileName []string) error {
+	pageNumber := 0
+	if len(fileName) > 0 {
+		var err error
+		pageNumber, err = strconv.Atoi(strings.TrimSuffix(fileName[0], ".html"))
+		if err != nil || pageNumber < 0 {
+			return ErrNotFound
+		}
+	}
+
+	nPosts, err := site.Renderer.RenderList(listType, groupName, pageNumber, w)
+	if err != nil {
+		return err
+	}
+
+	if nPosts == -1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// adjacentPosts returns the older and newer post of the post in index.
+func (site *Site) adjacentPosts(index int) (model.Post, model.Post) {
+	posts := site.Renderer.Posts
+	olderPost := model.Post{}
+	newerPost := model.Post{}
+
+	if index > 0 {
+		newerPost = posts[index-1]
+	}
+
+	if index < len(posts)-1 {
+		olderPost = posts[index+1]
+	}
+
+	return olderPost, newerPost
+}
+
+func (site *Site) progress(p Progress) {
+	if site.options.Progress != nil {
+		site.options.Progress(p)
+	}
+}
+
+// hasGroup checks if group with the name exists. The group without
+// name is named "uncategorized", like in the path of its list.
+func hasGroup(groups []model.Group, name string) bool {
+	for _, group := range groups {
+		if group.Name == name || (group.Name == "" && name == "uncategorized") {
+			return true
+		}
+	}
+
+	return false
+}
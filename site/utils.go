@@ -0,0 +1,134 @@
+package site
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/go-spook/spook/imaging"
+	"github.com/go-spook/spook/output"
+)
+
+// dirExists returns true if directory with the name is exist in fsys.
+func dirExists(fsys fs.FS, name string) bool {
+	if f, err := fs.Stat(fsys, name); err == nil && f.IsDir() {
+		return true
+	}
+
+	return false
+}
+
+// fileExists returns true if file in specified path is exist.
+func fileExists(path string) bool {
+	if f, err := os.Stat(path); err == nil && !f.IsDir() {
+		return true
+	}
+
+	return false
+}
+
+// outputWriter writes the files of the site into output sink. The rendered files use the
+// build time as their modification time, while the copied files keep their original
+// modification time, unless it's unknown or after the build time.
+type outputWriter struct {
+	sink      output.Sink
+	buildTime time.Time
+}
+
+// copyFile copies file with the name in fsys into the same name in output sink.
+func (out outputWriter) copyFile(fsys fs.FS, name string) error {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return newError(IOError, err, "failed to open "+name)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return newError(IOError, err, "failed to open "+name)
+	}
+
+	return out.createFile(name, out.modTime(info), func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		if err != nil {
+			return newError(IOError, err, "failed to copy "+name)
+		}
+		return nil
+	})
+}
+
+// copyStrippedImage copies JPEG image into output sink, without its EXIF metadata.
+func (out outputWriter) copyStrippedImage(fsys fs.FS, name string) error {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return newError(IOError, err, "failed to open "+name)
+	}
+
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return newError(IOError, err, "failed to read "+name)
+	}
+
+	content, err = imaging.StripEXIF(content)
+	if err != nil {
+		return newError(ContentError, err, "failed to strip EXIF from "+name)
+	}
+
+	return out.createFile(name, out.modTime(info), func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
+
+// writeFile creates the rendered file with the name, then writes into it using write.
+func (out outputWriter) writeFile(name string, write func(io.Writer) error) error {
+	return out.createFile(name, out.buildTime, write)
+}
+
+// createFile creates file with the name in output sink, then writes into it using write.
+// The failures of output sink are returned as IOError, while the error from write is
+// returned as it is, e.g. the failure while executing template.
+func (out outputWriter) createFile(name string, modTime time.Time, write func(io.Writer) error) error {
+	f, err := out.sink.Create(name, modTime)
+	if err != nil {
+		return newError(IOError, err, "failed to create "+name)
+	}
+
+	w := &sinkWriter{w: f}
+	err = write(w)
+	if w.err != nil {
+		err = newError(IOError, w.err, "failed to write "+name)
+	}
+
+	if errClose := f.Close(); err == nil && errClose != nil {
+		err = newError(IOError, errClose, "failed to write "+name)
+	}
+
+	return err
+}
+
+// sinkWriter is a writer that remembers the error from the file in output sink, so it
+// can be told apart from the other errors, e.g. failure while executing template.
+type sinkWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (sw *sinkWriter) Write(p []byte) (int, error) {
+	n, err := sw.w.Write(p)
+	if err != nil && sw.err == nil {
+		sw.err = err
+	}
+	return n, err
+}
+
+// modTime returns the modification time for the copied file.
+func (out outputWriter) modTime(info fs.FileInfo) time.Time {
+	modTime := info.ModTime()
+	if modTime.IsZero() || modTime.After(out.buildTime) {
+		return out.buildTime
+	}
+
+	return modTime
+}
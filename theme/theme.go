@@ -0,0 +1,246 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-spook/spook/model"
+)
+
+// ManifestFile is the name of file that describes the theme.
+const ManifestFile = "theme.toml"
+
+// RequiredTemplates is list of templates that must exist in every theme.
+var RequiredTemplates = []string{"list.html", "page.html", "post.html"}
+
+// Open opens the theme with specified name inside the theme directory of the site,
+// which file system is fsys. If the theme doesn't have manifest file, it will return
+// theme with default value.
+func Open(fsys fs.FS, name string) (model.Theme, error) {
+	if name == "" {
+		return model.Theme{}, fmt.Errorf("theme name is empty")
+	}
+
+	return OpenDir(fsys, path.Join("theme", name))
+}
+
+// OpenDir opens the theme that located in the specified directory inside fsys.
+func OpenDir(fsys fs.FS, themeDir string) (model.Theme, error) {
+	if f, err := fs.Stat(fsys, themeDir); err != nil {
+		return model.Theme{}, err
+	} else if !f.IsDir() {
+		return model.Theme{}, fmt.Errorf("%s is not a directory", themeDir)
+	}
+
+	themeFS, err := fs.Sub(fsys, themeDir)
+	if err != nil {
+		return model.Theme{}, err
+	}
+
+	theme := model.Theme{}
+	manifest, err := fs.ReadFile(themeFS, ManifestFile)
+	if err == nil {
+		_, err = toml.Decode(string(manifest), &theme)
+		if err != nil {
+			return model.Theme{}, fmt.Errorf("failed to parse manifest: %v", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return model.Theme{}, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	if theme.Name == "" {
+		theme.Name = path.Base(themeDir)
+	}
+
+	theme.Path = themeDir
+	theme.FS = themeFS
+	return theme, nil
+}
+
+// List returns all themes that installed in the theme directory of the site.
+// The hidden directories, e.g. .git, are not themes so they are skipped.
+func List(fsys fs.FS) ([]model.Theme, error) {
+	items, err := fs.ReadDir(fsys, "theme")
+	if err != nil {
+		return nil, err
+	}
+
+	themes := []model.Theme{}
+	for _, item := range items {
+		if !item.IsDir() || strings.HasPrefix(item.Name(), ".") {
+			continue
+		}
+
+		theme, err := OpenDir(fsys, path.Join("theme", item.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open theme %s: %v", item.Name(), err)
+		}
+
+		themes = append(themes, theme)
+	}
+
+	sort.Slice(themes, func(i int, j int) bool {
+		return themes[i].Path < themes[j].Path
+	})
+
+	return themes, nil
+}
+
+// Chain returns the theme with specified name, followed by its parent,
+// grandparent and so on. The parents are looked up in the theme directory of the site.
+func Chain(fsys fs.FS, name string) ([]model.Theme, error) {
+	chain := []model.Theme{}
+	visited := map[string]struct{}{}
+
+	for name != "" {
+		if _, exist := visited[name]; exist {
+			return nil, fmt.Errorf("theme %s is inherited cyclically", name)
+		}
+		visited[name] = struct{}{}
+
+		theme, err := Open(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open theme %s: %v", name, err)
+		}
+
+		chain = append(chain, theme)
+		name = theme.Parent
+	}
+
+	return chain, nil
+}
+
+// Validate checks whether the theme with specified name is usable by this version of Spook.
+// It makes sure the theme and its parents are compatible, and all required templates exist.
+func Validate(fsys fs.FS, name string) error {
+	chain, err := Chain(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	return ValidateChain(chain)
+}
+
+// ValidateChain checks whether the theme chain is usable by this version of Spook.
+// The first item in chain is the theme itself, followed by its parents.
+func ValidateChain(chain []model.Theme) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("theme chain is empty")
+	}
+
+	for _, theme := range chain {
+		if theme.MinVersion == "" {
+			continue
+		}
+
+		if _, err := parseVersion(theme.MinVersion); err != nil {
+			return fmt.Errorf("theme %s has invalid minimum version: %v", theme.Name, err)
+		}
+
+		if compareVersion(model.Version, theme.MinVersion) < 0 {
+			return fmt.Errorf("theme %s requires Spook %s or newer, current version is %s",
+				theme.Name, theme.MinVersion, model.Version)
+		}
+	}
+
+	missing := MissingTemplates(chain)
+	if len(missing) > 0 {
+		return fmt.Errorf("theme %s doesn't have required templates or they are empty: %v", chain[0].Name, missing)
+	}
+
+	return nil
+}
+
+// MissingTemplates returns the required templates which not found in the theme chain, or empty.
+func MissingTemplates(chain []model.Theme) []string {
+	required := append([]string{}, RequiredTemplates...)
+	for _, theme := range chain {
+		required = append(required, theme.Templates...)
+	}
+
+	missing := []string{}
+	visited := map[string]struct{}{}
+	for _, name := range required {
+		if _, exist := visited[name]; exist {
+			continue
+		}
+		visited[name] = struct{}{}
+
+		if _, found := FindFile(chain, name); !found {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// Templates returns the name of all templates in the theme chain, sorted by their name.
+// The templates are the HTML files in theme dir which not prefixed with underscore, along
+// with the templates that declared in the manifests.
+func Templates(chain []model.Theme) []string {
+	names := []string{}
+	visited := map[string]struct{}{}
+	addName := func(name string) {
+		if _, exist := visited[name]; !exist {
+			visited[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+
+	for _, theme := range chain {
+		items, err := fs.ReadDir(theme.FS, ".")
+		if err != nil {
+			continue
+		}
+
+		for _, item := range items {
+			name := item.Name()
+			if !item.IsDir() && path.Ext(name) == ".html" && !strings.HasPrefix(name, "_") {
+				addName(name)
+			}
+		}
+
+		for _, name := range theme.Templates {
+			addName(name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// FindFile looks for file with specified name in the theme chain, then returns
+// the theme that has it. The file in the child theme is preferred over the file
+// in its parent. Returns false if the file is not found. The empty file is treated
+// as not exist, since an empty template can't be rendered.
+func FindFile(chain []model.Theme, name string) (model.Theme, bool) {
+	for _, theme := range chain {
+		if f, err := fs.Stat(theme.FS, name); err == nil && !f.IsDir() && f.Size() > 0 {
+			return theme, true
+		}
+	}
+
+	return model.Theme{}, false
+}
+
+// Params merges the default params from the theme chain with the params from config file.
+// The params in config file override the params in child theme, which override its parents.
+func Params(chain []model.Theme, config model.Config) map[string]interface{} {
+	params := map[string]interface{}{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for key, value := range chain[i].Params {
+			params[key] = value
+		}
+	}
+
+	for key, value := range config.Params {
+		params[key] = value
+	}
+
+	return params
+}
@@ -0,0 +1,47 @@
+package theme
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseVersion parses version string with format "major.minor.patch".
+// The "v" prefix and the missing minor or patch number are allowed.
+func parseVersion(version string) ([3]int, error) {
+	result := [3]int{}
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return result, fmt.Errorf("version %q has too many parts", version)
+	}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return result, fmt.Errorf("version %q is not valid", version)
+		}
+		result[i] = n
+	}
+
+	return result, nil
+}
+
+// compareVersion compares two version strings. Returns -1 if a is older than b,
+// 1 if a is newer than b and 0 if both are equal. Invalid version treated as 0.0.0.
+func compareVersion(a, b string) int {
+	va, _ := parseVersion(a)
+	vb, _ := parseVersion(b)
+
+	for i := 0; i < 3; i++ {
+		switch {
+		case va[i] < vb[i]:
+			return -1
+		case va[i] > vb[i]:
+			return 1
+		}
+	}
+
+	return 0
+}
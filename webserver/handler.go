@@ -1,213 +1,192 @@
 package webserver
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"path"
 	fp "path/filepath"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-spook/spook/imaging"
 	"github.com/go-spook/spook/model"
-	"github.com/go-spook/spook/parser"
-	"github.com/go-spook/spook/renderer"
+	"github.com/go-spook/spook/site"
+	"github.com/go-spook/spook/theme"
 	"github.com/julienschmidt/httprouter"
 )
 
-// handler is handler for serving the web interface.
+// handler is handler for serving the web interface. The files of site
+// are read from FS, which is the file system of the site root dir.
 type handler struct {
 	Config  model.Config
 	RootDir string
-}
+	FS      fs.FS
 
-func (hdl *handler) serveThemeFiles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	filepath := fp.Join("theme", hdl.Config.Theme, r.URL.Path)
-	http.ServeFile(w, r, filepath)
+	mutex       sync.Mutex
+	siteVersion string
+	site        *site.Site
 }
 
-func (hdl *handler) serveStaticFiles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	filepath := fp.Join("static", ps.ByName("filepath"))
-	http.ServeFile(w, r, filepath)
-}
+// loadSite returns the loaded site, with its posts and pages already parsed. The site
+// is kept in memory, and only loaded again when any file in content and theme dir,
+// or the config file, is changed. Since checking the changes needs to walk all of
+// those files, it's only done if checkChanges is true, i.e. when serving HTML. The
+// other files, e.g. assets, are requested by the HTML, so they use the same site.
+func (hdl *handler) loadSite(checkChanges bool) (*site.Site, error) {
+	hdl.mutex.Lock()
+	loadedSite := hdl.site
+	hdl.mutex.Unlock()
 
-func (hdl *handler) serveFrontPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	// Parse all posts and pages
-	psr := parser.Parser{
-		Config:  hdl.Config,
-		RootDir: hdl.RootDir,
+	if loadedSite != nil && !checkChanges {
+		return loadedSite, nil
 	}
 
-	parsedPosts, err := psr.ParsePosts()
-	checkError(err)
-
-	pages, err := psr.ParsePages()
-	checkError(err)
-
-	// Render and serve HTML
-	rd := renderer.Renderer{
-		Config:     hdl.Config,
-		Pages:      pages,
-		Posts:      parsedPosts.Posts,
-		Tags:       parsedPosts.Tags,
-		Categories: parsedPosts.Categories,
-		RootDir:    hdl.RootDir,
+	version, err := hdl.contentVersion()
+	if err != nil {
+		return nil, err
 	}
 
-	err = rd.RenderFrontPage(w)
-	checkError(err)
-}
+	hdl.mutex.Lock()
+	defer hdl.mutex.Unlock()
 
-func (hdl *handler) serveList(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	// Get parameter
-	groupName := ps.ByName("name")
-	strPageNumber := ps.ByName("n")
-	pageNumber, _ := strconv.Atoi(strPageNumber)
-	if pageNumber < 1 {
-		pageNumber = 1
+	if hdl.site != nil && version == hdl.siteVersion {
+		return hdl.site, nil
 	}
 
-	// Parse all posts and pages
-	psr := parser.Parser{
-		Config:  hdl.Config,
-		RootDir: hdl.RootDir,
+	s, err := site.Load(hdl.RootDir, site.Options{FS: hdl.FS})
+	if err != nil {
+		return nil, err
 	}
 
-	parsedPosts, err := psr.ParsePosts()
-	checkError(err)
-
-	pages, err := psr.ParsePages()
-	checkError(err)
+	hdl.site = s
+	hdl.siteVersion = version
+	return s, nil
+}
 
-	// Render and serve HTML
-	rd := renderer.Renderer{
-		Config:     hdl.Config,
-		Pages:      pages,
-		Posts:      parsedPosts.Posts,
-		Tags:       parsedPosts.Tags,
-		Categories: parsedPosts.Categories,
-		RootDir:    hdl.RootDir,
+// contentVersion returns the hash of name, size and modification time of the
+// config file and all files inside the post, page and theme dir, which changed
+// whenever any of the files is changed.
+func (hdl *handler) contentVersion() (string, error) {
+	hasher := sha256.New()
+	for _, dirName := range []string{"config.toml", "post", "page", "theme"} {
+		err := fs.WalkDir(hdl.FS, dirName, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(hasher, "%s:%d:%d\n", name, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+
+		if err != nil {
+			return "", err
+		}
 	}
 
-	var listType renderer.ListType
-	if strings.HasPrefix(r.URL.Path, "/category") {
-		listType = renderer.CATEGORY
-	} else if strings.HasPrefix(r.URL.Path, "/tag") {
-		listType = renderer.TAG
-	} else {
-		listType = renderer.DEFAULT
-		groupName = ""
-	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
 
-	_, err = rd.RenderList(listType, groupName, pageNumber, w)
+// serveThemeFiles serves the processed assets and the files inside sub directories
+// of theme, which are the files that copied into output dir by build. The theme
+// files at root of theme dir, e.g. the templates, are not served.
+func (hdl *handler) serveThemeFiles(w http.ResponseWriter, r *http.Request) {
+	s, err := hdl.loadSite(false)
 	checkError(err)
-}
 
-func (hdl *handler) servePage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	pagePath := fp.Join("page", ps.ByName("name"))
+	// Serve the processed assets, so the bundles and fingerprinted files are available
+	// as well. If file is not found, serve it from the theme that has it.
+	if processed, exist := s.Assets.Find(r.URL.Path); exist {
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(processed.Content))
+		return
+	}
 
-	// Check if this is request for asset file of a page
-	if filepath := ps.ByName("filepath"); filepath != "" && filepath != "/" {
-		filepath = fp.Join(pagePath, filepath)
-		http.ServeFile(w, r, filepath)
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if !strings.Contains(name, "/") {
+		http.NotFound(w, r)
 		return
 	}
 
-	// Parse all posts and pages
-	psr := parser.Parser{
-		Config:  hdl.Config,
-		RootDir: hdl.RootDir,
+	th, found := theme.FindFile(s.Themes, name)
+	if !found {
+		http.NotFound(w, r)
+		return
 	}
 
-	parsedPosts, err := psr.ParsePosts()
-	checkError(err)
+	http.FileServer(http.FS(th.FS)).ServeHTTP(w, r)
+}
 
-	pages, err := psr.ParsePages()
-	checkError(err)
+func (hdl *handler) serveStaticFiles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	http.FileServer(http.FS(hdl.FS)).ServeHTTP(w, r)
+}
 
-	// Find the wanted page
-	page := model.Page{}
-	for i := 0; i < len(pages); i++ {
-		if pages[i].Path == "/"+pagePath {
-			page = pages[i]
-			page.Path = strings.TrimPrefix(page.Path, "/")
-			break
+// serveBundleFile serves the file inside directory of post or page. If the file is
+// not exist but it's a resized variant of an image, the variant is generated first
+// and served from the cache dir.
+func (hdl *handler) serveBundleFile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if _, err := fs.Stat(hdl.FS, name); errors.Is(err, fs.ErrNotExist) {
+		if _, _, isVariant := imaging.ParseVariantURL(r.URL.Path); isVariant {
+			s, err := hdl.loadSite(false)
+			checkError(err)
+
+			cachePath, err := s.Renderer.Images.CachedFile(r.URL.Path)
+			if err == nil {
+				http.ServeFile(w, r, cachePath)
+				return
+			}
 		}
 	}
 
-	// Render and serve HTML
-	rd := renderer.Renderer{
-		Config:     hdl.Config,
-		Pages:      pages,
-		Posts:      parsedPosts.Posts,
-		Tags:       parsedPosts.Tags,
-		Categories: parsedPosts.Categories,
-		RootDir:    hdl.RootDir,
-	}
-
-	err = rd.RenderPage(page, w)
-	checkError(err)
+	http.FileServer(http.FS(hdl.FS)).ServeHTTP(w, r)
 }
 
-func (hdl *handler) servePost(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	postPath := fp.Join("post", ps.ByName("name"))
+// serveHTML renders and serves the HTML for the URL path, which
+// is the same as the file that written by build for the path.
+func (hdl *handler) serveHTML(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s, err := hdl.loadSite(true)
+	checkError(err)
 
-	// Check if this is request for asset file of a post
-	if filepath := ps.ByName("filepath"); filepath != "" && filepath != "/" {
-		filepath = fp.Join(postPath, filepath)
-		http.ServeFile(w, r, filepath)
+	err = s.Render(r.URL.Path, w)
+	if err == site.ErrNotFound {
+		http.NotFound(w, r)
 		return
 	}
 
-	// Parse all posts and pages
-	psr := parser.Parser{
-		Config:  hdl.Config,
-		RootDir: hdl.RootDir,
-	}
-
-	parsedPosts, err := psr.ParsePosts()
 	checkError(err)
+}
 
-	pages, err := psr.ParsePages()
-	checkError(err)
-
-	// Find the wanted post
-	postIndex := -1
-	for i := 0; i < len(parsedPosts.Posts); i++ {
-		if parsedPosts.Posts[i].Path == "/"+postPath {
-			postIndex = i
-			break
-		}
-	}
-
-	if postIndex == -1 {
-		panic(fmt.Errorf("post is not found"))
-	}
-
-	currentPost := parsedPosts.Posts[postIndex]
-	currentPost.Path = strings.Trim(currentPost.Path, "/")
-
-	newerPost := model.Post{}
-	olderPost := model.Post{}
-
-	if postIndex > 0 {
-		newerPost = parsedPosts.Posts[postIndex-1]
+func (hdl *handler) servePage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Check if this is request for asset file of a page
+	if filepath := ps.ByName("filepath"); filepath != "" && filepath != "/" {
+		hdl.serveBundleFile(w, r)
+		return
 	}
 
-	if postIndex < len(parsedPosts.Posts)-1 {
-		olderPost = parsedPosts.Posts[postIndex+1]
-	}
+	hdl.serveHTML(w, r, ps)
+}
 
-	// Render and serve HTML
-	rd := renderer.Renderer{
-		Config:     hdl.Config,
-		Pages:      pages,
-		Posts:      parsedPosts.Posts,
-		Tags:       parsedPosts.Tags,
-		Categories: parsedPosts.Categories,
-		RootDir:    hdl.RootDir,
+func (hdl *handler) servePost(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Check if this is request for asset file of a post
+	if filepath := ps.ByName("filepath"); filepath != "" && filepath != "/" {
+		hdl.serveBundleFile(w, r)
+		return
 	}
 
-	err = rd.RenderPost(currentPost, olderPost, newerPost, w)
-	checkError(err)
+	hdl.serveHTML(w, r, ps)
 }
 
 func (hdl *handler) addSuffixSlash(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -18,28 +18,30 @@ func Start(rootDir string, config model.Config, port int) error {
 	hdl := handler{
 		Config:  config,
 		RootDir: rootDir,
+		FS:      os.DirFS(rootDir),
 	}
 
 	// Create router
 	router := httprouter.New()
 
-	router.GET("/js/*filepath", hdl.serveThemeFiles)
-	router.GET("/res/*filepath", hdl.serveThemeFiles)
-	router.GET("/css/*filepath", hdl.serveThemeFiles)
 	router.GET("/static/*filepath", hdl.serveStaticFiles)
 
-	router.GET("/", hdl.serveFrontPage)
-	router.GET("/posts", hdl.serveList)
-	router.GET("/posts/:n", hdl.serveList)
-	router.GET("/category/:name", hdl.serveList)
-	router.GET("/category/:name/:n", hdl.serveList)
-	router.GET("/tag/:name", hdl.serveList)
-	router.GET("/tag/:name/:n", hdl.serveList)
+	router.GET("/", hdl.serveHTML)
+	router.GET("/posts", hdl.serveHTML)
+	router.GET("/posts/:n", hdl.serveHTML)
+	router.GET("/category/:name", hdl.serveHTML)
+	router.GET("/category/:name/:n", hdl.serveHTML)
+	router.GET("/tag/:name", hdl.serveHTML)
+	router.GET("/tag/:name/:n", hdl.serveHTML)
 	router.GET("/page/:name", hdl.addSuffixSlash)
 	router.GET("/page/:name/*filepath", hdl.servePage)
 	router.GET("/post/:name", hdl.addSuffixSlash)
 	router.GET("/post/:name/*filepath", hdl.servePost)
 
+	// The theme files and processed assets can be in any directory, e.g. a bundle
+	// could be written to /bundle/app.js, so they're served from unmatched routes.
+	router.NotFound = http.HandlerFunc(hdl.serveThemeFiles)
+
 	// Route for panic
 	router.PanicHandler = func(w http.ResponseWriter, r *http.Request, arg interface{}) {
 		http.Error(w, fmt.Sprint(arg), 500)